@@ -0,0 +1,288 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitState uint8
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", uint8(s))
+	}
+}
+
+// CircuitBreakerPolicy configures per-host circuit breaking. A closed circuit
+// opens when at least MinRequests attempts in the current Window failed at
+// FailureRatio or more. After CoolDown it lets HalfOpenRequests probes through
+// and closes again only if all of them succeed. IsFailure defaults to
+// transport errors and 5xx responses; cancellation by the caller is ignored.
+type CircuitBreakerPolicy struct {
+	FailureRatio     float64
+	MinRequests      int
+	Window           time.Duration
+	CoolDown         time.Duration
+	HalfOpenRequests int
+	IsFailure        func(statusCode int, err error) bool
+	OnStateChange    func(CircuitEvent)
+}
+
+type CircuitEvent struct {
+	Host     string
+	From     CircuitState
+	To       CircuitState
+	Requests int
+	Failures int
+}
+
+// CircuitOpenError is returned without sending a request while the circuit
+// for Host is open. RetryAfter estimates when a probe will be allowed.
+type CircuitOpenError struct {
+	Host       string
+	State      CircuitState
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v for host %q", ErrCircuitOpen, e.Host)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// WithCircuitBreaker enables a circuit breaker keyed by request host. Every
+// attempt, including retries, is checked and recorded, so an open circuit
+// also stops the remaining retries of a call.
+func WithCircuitBreaker(policy CircuitBreakerPolicy) Option {
+	return func(client *Client) {
+		client.breaker = newCircuitBreaker(policy)
+	}
+}
+
+// CircuitState reports the circuit state for host. Clients without a circuit
+// breaker always report CircuitClosed.
+func (c *Client) CircuitState(host string) CircuitState {
+	if c == nil || c.breaker == nil {
+		return CircuitClosed
+	}
+	return c.breaker.state(strings.ToLower(host))
+}
+
+// circuitSweep is the number of tracked hosts above which closed, idle
+// circuits are dropped. Like hostLimiterSweep, the threshold doubles with the
+// circuits that are kept.
+const circuitSweep = 64
+
+type circuit struct {
+	state          CircuitState
+	windowStart    time.Time
+	openedAt       time.Time
+	requests       int
+	failures       int
+	probes         int
+	probeSuccesses int
+	pending        int
+}
+
+type circuitBreaker struct {
+	mutex    sync.Mutex
+	policy   CircuitBreakerPolicy
+	circuits map[string]*circuit
+	sweepAt  int
+	now      func() time.Time
+}
+
+func newCircuitBreaker(policy CircuitBreakerPolicy) *circuitBreaker {
+	if policy.FailureRatio <= 0 || policy.FailureRatio > 1 {
+		policy.FailureRatio = 0.5
+	}
+	if policy.MinRequests <= 0 {
+		policy.MinRequests = 10
+	}
+	if policy.Window <= 0 {
+		policy.Window = 30 * time.Second
+	}
+	if policy.CoolDown <= 0 {
+		policy.CoolDown = 10 * time.Second
+	}
+	if policy.HalfOpenRequests <= 0 {
+		policy.HalfOpenRequests = 1
+	}
+	if policy.IsFailure == nil {
+		policy.IsFailure = func(statusCode int, err error) bool {
+			return err != nil || statusCode >= http.StatusInternalServerError
+		}
+	}
+	return &circuitBreaker{
+		policy:   policy,
+		circuits: make(map[string]*circuit),
+		sweepAt:  circuitSweep,
+		now:      time.Now,
+	}
+}
+
+func (b *circuitBreaker) state(host string) CircuitState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	current, ok := b.circuits[host]
+	if !ok {
+		return CircuitClosed
+	}
+	if current.state == CircuitOpen && b.now().Sub(current.openedAt) >= b.policy.CoolDown {
+		return CircuitHalfOpen
+	}
+	return current.state
+}
+
+// allow reserves permission for one attempt. The returned function records
+// its outcome and must be called exactly once.
+func (b *circuitBreaker) allow(host string) (func(statusCode int, err error), error) {
+	b.mutex.Lock()
+	var events []CircuitEvent
+	now := b.now()
+	current := b.circuits[host]
+	if current == nil {
+		if len(b.circuits) >= b.sweepAt {
+			b.sweep(now)
+		}
+		current = &circuit{windowStart: now}
+		b.circuits[host] = current
+	}
+	if current.state == CircuitOpen {
+		remaining := b.policy.CoolDown - now.Sub(current.openedAt)
+		if remaining > 0 {
+			b.mutex.Unlock()
+			return nil, &CircuitOpenError{Host: host, State: CircuitOpen, RetryAfter: remaining}
+		}
+		events = append(events, b.transition(host, current, CircuitHalfOpen, now))
+	}
+	probe := current.state == CircuitHalfOpen
+	if probe {
+		if current.probes >= b.policy.HalfOpenRequests {
+			b.mutex.Unlock()
+			b.notify(events)
+			return nil, &CircuitOpenError{Host: host, State: CircuitHalfOpen}
+		}
+		current.probes++
+	}
+	current.pending++
+	b.mutex.Unlock()
+	b.notify(events)
+
+	var once sync.Once
+	return func(statusCode int, err error) {
+		once.Do(func() {
+			b.record(host, probe, statusCode, err)
+		})
+	}, nil
+}
+
+func (b *circuitBreaker) record(host string, probe bool, statusCode int, err error) {
+	ignored := errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrCircuitOpen)
+	failed := !ignored && b.policy.IsFailure(statusCode, err)
+
+	b.mutex.Lock()
+	var events []CircuitEvent
+	current := b.circuits[host]
+	now := b.now()
+	if current != nil {
+		current.pending--
+	}
+	switch {
+	case current == nil:
+	case probe && current.state == CircuitHalfOpen:
+		current.probes--
+		switch {
+		case ignored:
+		case failed:
+			events = append(events, b.transition(host, current, CircuitOpen, now))
+		default:
+			current.probeSuccesses++
+			if current.probeSuccesses >= b.policy.HalfOpenRequests {
+				events = append(events, b.transition(host, current, CircuitClosed, now))
+			}
+		}
+	case !probe && current.state == CircuitClosed && !ignored:
+		if now.Sub(current.windowStart) >= b.policy.Window {
+			current.windowStart = now
+			current.requests = 0
+			current.failures = 0
+		}
+		current.requests++
+		if failed {
+			current.failures++
+		}
+		if current.requests >= b.policy.MinRequests &&
+			float64(current.failures) >= b.policy.FailureRatio*float64(current.requests) {
+			events = append(events, b.transition(host, current, CircuitOpen, now))
+		}
+	}
+	b.mutex.Unlock()
+	b.notify(events)
+}
+
+// sweep drops closed circuits with no attempt in flight whose window has
+// expired, since a fresh circuit behaves the same. The caller holds the mutex.
+func (b *circuitBreaker) sweep(now time.Time) {
+	for host, current := range b.circuits {
+		if current.state == CircuitClosed && current.pending == 0 &&
+			now.Sub(current.windowStart) >= b.policy.Window {
+			delete(b.circuits, host)
+		}
+	}
+	b.sweepAt = max(2*len(b.circuits), circuitSweep)
+}
+
+func (b *circuitBreaker) transition(host string, current *circuit, to CircuitState, now time.Time) CircuitEvent {
+	event := CircuitEvent{
+		Host:     host,
+		From:     current.state,
+		To:       to,
+		Requests: current.requests,
+		Failures: current.failures,
+	}
+	current.state = to
+	current.probes = 0
+	current.probeSuccesses = 0
+	switch to {
+	case CircuitOpen:
+		current.openedAt = now
+	case CircuitClosed:
+		current.windowStart = now
+		current.requests = 0
+		current.failures = 0
+	}
+	return event
+}
+
+func (b *circuitBreaker) notify(events []CircuitEvent) {
+	if b.policy.OnStateChange == nil {
+		return
+	}
+	for _, event := range events {
+		b.policy.OnStateChange(event)
+	}
+}
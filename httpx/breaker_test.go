@@ -0,0 +1,144 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	var healthy atomic.Bool
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		if !healthy.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var mutex sync.Mutex
+	var transitions []string
+	client := New(
+		WithRetry(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Microsecond}),
+		WithCircuitBreaker(CircuitBreakerPolicy{
+			MinRequests: 2,
+			CoolDown:    20 * time.Millisecond,
+			OnStateChange: func(event CircuitEvent) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				transitions = append(transitions, event.From.String()+">"+event.To.String())
+			},
+		}),
+	)
+	_, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil)
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) || openErr.RetryAfter <= 0 {
+		t.Fatalf("error = %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Fatalf("requests while closed = %d", requests.Load())
+	}
+	if _, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("fail fast error = %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Fatalf("requests while open = %d", requests.Load())
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	healthy.Store(true)
+	response, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil || !response.OK() {
+		t.Fatalf("probe response = %+v, error = %v", response, err)
+	}
+	host := requestHost(server.URL)
+	if state := client.CircuitState(host); state != CircuitClosed {
+		t.Fatalf("state = %v", state)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	want := []string{"closed>open", "open>half-open", "half-open>closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %q", transitions)
+	}
+	for index := range want {
+		if transitions[index] != want[index] {
+			t.Fatalf("transitions = %q", transitions)
+		}
+	}
+}
+
+func TestCircuitBreakerFailedProbeReopens(t *testing.T) {
+	breaker := newCircuitBreaker(CircuitBreakerPolicy{MinRequests: 1, CoolDown: time.Minute})
+	now := time.Unix(1000, 0)
+	breaker.now = func() time.Time { return now }
+
+	record, err := breaker.allow("api.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(http.StatusBadGateway, nil)
+	if _, err := breaker.allow("api.example"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("open error = %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	probe, err := breaker.allow("api.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var halfOpenErr *CircuitOpenError
+	if _, err := breaker.allow("api.example"); !errors.As(err, &halfOpenErr) || halfOpenErr.State != CircuitHalfOpen {
+		t.Fatalf("second probe error = %v", err)
+	}
+	probe(0, errors.New("connection refused"))
+	if state := breaker.state("api.example"); state != CircuitOpen {
+		t.Fatalf("state = %v", state)
+	}
+	if _, err := breaker.allow("other.example"); err != nil {
+		t.Fatalf("other host error = %v", err)
+	}
+}
+
+func TestCircuitBreakerEvictsIdleCircuits(t *testing.T) {
+	breaker := newCircuitBreaker(CircuitBreakerPolicy{MinRequests: 1, Window: time.Second, CoolDown: time.Hour})
+	now := time.Unix(1000, 0)
+	breaker.now = func() time.Time { return now }
+
+	record, err := breaker.allow("down.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(http.StatusBadGateway, nil)
+	busy, err := breaker.allow("busy.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index := range 10 * circuitSweep {
+		record, err := breaker.allow(fmt.Sprintf("%d.example", index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		record(http.StatusOK, nil)
+		now = now.Add(time.Second)
+	}
+	if len(breaker.circuits) > circuitSweep {
+		t.Fatalf("tracked circuits = %d", len(breaker.circuits))
+	}
+	if breaker.circuits["busy.example"] == nil {
+		t.Fatal("circuit with an attempt in flight was evicted")
+	}
+	if state := breaker.state("down.example"); state != CircuitOpen {
+		t.Fatalf("open circuit state = %v", state)
+	}
+	busy(http.StatusOK, nil)
+}
@@ -0,0 +1,399 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a stored response. RequestHeader holds the request values of
+// the fields named by the response Vary header, and Redirects the redirects
+// followed to reach the response.
+type CacheEntry struct {
+	StatusCode    int
+	Header        http.Header
+	Body          []byte
+	RequestHeader http.Header
+	Redirects     []Redirect
+	StoredAt      time.Time
+}
+
+// CacheStorage stores cached responses. Implementations must be safe for
+// concurrent use and must treat entries as immutable. Storage failures should
+// be reported as misses; the cache is always allowed to go to the network.
+type CacheStorage interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+// CachePolicy configures the response cache. Storage defaults to a
+// MemoryCache with default limits, and MaxEntryBytes defaults to the client
+// body limit; larger responses are streamed through without being stored.
+type CachePolicy struct {
+	Storage       CacheStorage
+	MaxEntryBytes int64
+}
+
+// WithCache caches successful GET responses without a request body. It
+// honours Cache-Control max-age, no-cache, no-store, must-revalidate, and
+// stale-while-revalidate, falls back to Expires, and revalidates stale
+// entries with If-None-Match and If-Modified-Since. Each URL keeps a single
+// variant; a request whose Vary fields differ replaces it. Cached responses
+// carry an Age header. A response is stored once its body has been read to
+// the end, and event streams are never stored. Range and conditional requests
+// bypass the cache, and requests with an Authorization or Cookie header only
+// use responses marked public or s-maxage.
+func WithCache(policy CachePolicy) Option {
+	return func(client *Client) {
+		if policy.Storage == nil {
+			policy.Storage = NewMemoryCache(0, 0)
+		}
+		client.cache = &responseCache{
+			policy:       policy,
+			revalidating: make(map[string]struct{}),
+			now:          time.Now,
+		}
+	}
+}
+
+type responseCache struct {
+	mutex        sync.Mutex
+	policy       CachePolicy
+	revalidating map[string]struct{}
+	now          func() time.Time
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	directives := make(cacheControl)
+	for _, value := range header.Values("Cache-Control") {
+		for _, item := range strings.Split(value, ",") {
+			name, argument, _ := strings.Cut(strings.TrimSpace(item), "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" {
+				directives[name] = strings.Trim(strings.TrimSpace(argument), `"`)
+			}
+		}
+	}
+	return directives
+}
+
+func (c cacheControl) has(name string) bool {
+	_, ok := c[name]
+	return ok
+}
+
+func (c cacheControl) seconds(name string) (time.Duration, bool) {
+	value, ok := c[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func (c *Client) doCached(ctx context.Context, request Request) (*StreamResponse, error) {
+	cache := c.cache
+	requestHeader := c.headers.Clone()
+	mergeHeaders(requestHeader, request.Header)
+	requestControl := parseCacheControl(requestHeader)
+	if requestControl.has("no-store") || bypassesCache(requestHeader) {
+		return c.doWithRetry(ctx, request)
+	}
+
+	key := http.MethodGet + " " + request.URL
+	entry, ok := cache.lookup(key, requestHeader)
+	if ok && hasCredentials(requestHeader) && !parseCacheControl(entry.Header).shared() {
+		entry, ok = nil, false
+	}
+	if ok && !requestControl.has("no-cache") {
+		age := cache.now().Sub(entry.StoredAt)
+		control := parseCacheControl(entry.Header)
+		lifetime := freshnessLifetime(entry, control)
+		if age < lifetime {
+			return entry.streamResponse(age), nil
+		}
+		if window, found := control.seconds("stale-while-revalidate"); found &&
+			!control.has("must-revalidate") && age < lifetime+window {
+			c.revalidateInBackground(ctx, key, request, requestHeader, entry)
+			return entry.streamResponse(age), nil
+		}
+	}
+	if !ok {
+		entry = nil
+	}
+	return c.fetchForCache(ctx, key, request, requestHeader, entry)
+}
+
+// fetchForCache sends request, conditionally when entry has validators, and
+// stores a cacheable result.
+func (c *Client) fetchForCache(
+	ctx context.Context,
+	key string,
+	request Request,
+	requestHeader http.Header,
+	entry *CacheEntry,
+) (*StreamResponse, error) {
+	cache := c.cache
+	if entry != nil {
+		request.Header = request.Header.Clone()
+		if request.Header == nil {
+			request.Header = make(http.Header)
+		}
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			request.Header.Set("If-None-Match", etag)
+		}
+		if modified := entry.Header.Get("Last-Modified"); modified != "" {
+			request.Header.Set("If-Modified-Since", modified)
+		}
+	}
+	response, err := c.doWithRetry(ctx, request)
+	if err != nil {
+		return response, err
+	}
+	if entry != nil && response.StatusCode == http.StatusNotModified {
+		drainAndClose(response.Body)
+		updated := entry.refreshed(response.Header, cache.now())
+		cache.policy.Storage.Set(key, updated)
+		return updated.streamResponse(0), nil
+	}
+	return cache.store(key, requestHeader, response, c.cacheEntryLimit())
+}
+
+func (c *Client) revalidateInBackground(
+	ctx context.Context,
+	key string,
+	request Request,
+	requestHeader http.Header,
+	entry *CacheEntry,
+) {
+	cache := c.cache
+	cache.mutex.Lock()
+	if _, running := cache.revalidating[key]; running {
+		cache.mutex.Unlock()
+		return
+	}
+	cache.revalidating[key] = struct{}{}
+	cache.mutex.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer func() {
+			cache.mutex.Lock()
+			delete(cache.revalidating, key)
+			cache.mutex.Unlock()
+		}()
+		response, err := c.fetchForCache(ctx, key, request, requestHeader, entry)
+		if err == nil {
+			// Reading to the end lets store keep the new body.
+			_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, c.cacheEntryLimit()+1))
+			_ = response.Body.Close()
+		}
+	}()
+}
+
+func (c *Client) cacheEntryLimit() int64 {
+	if c.cache.policy.MaxEntryBytes > 0 {
+		return c.cache.policy.MaxEntryBytes
+	}
+	return c.bodyLimit()
+}
+
+func (r *responseCache) lookup(key string, requestHeader http.Header) (*CacheEntry, bool) {
+	entry, ok := r.policy.Storage.Get(key)
+	if !ok || entry == nil {
+		return nil, false
+	}
+	for _, field := range varyFields(entry.Header) {
+		if strings.Join(requestHeader.Values(field), ",") != strings.Join(entry.RequestHeader.Values(field), ",") {
+			return nil, false
+		}
+	}
+	return entry, true
+}
+
+// store arranges for a cacheable response to be stored once the caller has
+// read its body to the end. The body is copied as it is read, so streams are
+// not delayed; bodies larger than limit, responses whose body is closed early,
+// and event streams are not stored. Responses to requests with credentials
+// are stored only when marked public or s-maxage.
+func (r *responseCache) store(
+	key string,
+	requestHeader http.Header,
+	response *StreamResponse,
+	limit int64,
+) (*StreamResponse, error) {
+	control := parseCacheControl(response.Header)
+	if response.StatusCode != http.StatusOK || response.Body == nil || control.has("no-store") ||
+		response.ContentLength > limit || (hasCredentials(requestHeader) && !control.shared()) {
+		return response, nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType == "text/event-stream" {
+		return response, nil
+	}
+	vary := varyFields(response.Header)
+	for _, field := range vary {
+		if field == "*" {
+			return response, nil
+		}
+	}
+	candidate := &CacheEntry{
+		StatusCode: response.StatusCode,
+		Header:     response.Header.Clone(),
+		Redirects:  slices.Clone(response.Redirects),
+		StoredAt:   r.now(),
+	}
+	if age, err := strconv.ParseInt(response.Header.Get("Age"), 10, 64); err == nil && age > 0 {
+		candidate.StoredAt = candidate.StoredAt.Add(-time.Duration(age) * time.Second)
+	}
+	if freshnessLifetime(candidate, control) <= 0 &&
+		response.Header.Get("ETag") == "" && response.Header.Get("Last-Modified") == "" {
+		return response, nil
+	}
+	candidate.RequestHeader = make(http.Header, len(vary))
+	for _, field := range vary {
+		if values := requestHeader.Values(field); len(values) > 0 {
+			candidate.RequestHeader[http.CanonicalHeaderKey(field)] = append([]string(nil), values...)
+		}
+	}
+	response.Body = &cacheFillReader{
+		body:  response.Body,
+		limit: limit,
+		complete: func(data []byte) {
+			candidate.Body = data
+			r.policy.Storage.Set(key, candidate)
+		},
+	}
+	return response, nil
+}
+
+// cacheFillReader copies a response body as it is read and hands the copy to
+// complete at the end of the body, unless it grew beyond limit.
+type cacheFillReader struct {
+	body     io.ReadCloser
+	limit    int64
+	data     []byte
+	overflow bool
+	complete func([]byte)
+}
+
+func (r *cacheFillReader) Read(target []byte) (int, error) {
+	count, err := r.body.Read(target)
+	if !r.overflow {
+		if int64(len(r.data)+count) > r.limit {
+			r.overflow, r.data = true, nil
+		} else {
+			r.data = append(r.data, target[:count]...)
+		}
+	}
+	if errors.Is(err, io.EOF) && !r.overflow && r.complete != nil {
+		r.complete(r.data)
+		r.complete = nil
+	}
+	return count, err
+}
+
+func (r *cacheFillReader) Close() error {
+	return r.body.Close()
+}
+
+// cacheBypassHeaders are request fields asking for something other than the
+// stored full response, so requests carrying them skip the cache.
+var cacheBypassHeaders = []string{
+	"Range",
+	"If-Range",
+	"If-Match",
+	"If-None-Match",
+	"If-Modified-Since",
+	"If-Unmodified-Since",
+}
+
+func bypassesCache(requestHeader http.Header) bool {
+	for _, name := range cacheBypassHeaders {
+		if requestHeader.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCredentials reports whether a request identifies its user, so its
+// response may be private to that user.
+func hasCredentials(requestHeader http.Header) bool {
+	return requestHeader.Get("Authorization") != "" || requestHeader.Get("Cookie") != ""
+}
+
+// shared reports whether a response may be served to other users.
+func (c cacheControl) shared() bool {
+	return c.has("public") || c.has("s-maxage")
+}
+
+func freshnessLifetime(entry *CacheEntry, control cacheControl) time.Duration {
+	if control.has("no-cache") {
+		return 0
+	}
+	if maxAge, ok := control.seconds("max-age"); ok {
+		return maxAge
+	}
+	expires, err := http.ParseTime(entry.Header.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	date, err := http.ParseTime(entry.Header.Get("Date"))
+	if err != nil {
+		date = entry.StoredAt
+	}
+	return expires.Sub(date)
+}
+
+func varyFields(header http.Header) []string {
+	var fields []string
+	for _, value := range header.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// refreshed returns a copy updated with the headers of a 304 response.
+func (e *CacheEntry) refreshed(header http.Header, now time.Time) *CacheEntry {
+	updated := *e
+	updated.Header = e.Header.Clone()
+	for key, values := range header {
+		switch http.CanonicalHeaderKey(key) {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		updated.Header[key] = append([]string(nil), values...)
+	}
+	updated.StoredAt = now
+	return &updated
+}
+
+func (e *CacheEntry) streamResponse(age time.Duration) *StreamResponse {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	return &StreamResponse{
+		StatusCode:    e.StatusCode,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Redirects:     slices.Clone(e.Redirects),
+	}
+}
@@ -0,0 +1,241 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheServesFreshAndRevalidatesStale(t *testing.T) {
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(`{"value":1}`))
+	}))
+	defer server.Close()
+
+	client := New(WithCache(CachePolicy{}))
+	now := time.Now()
+	client.cache.now = func() time.Time { return now }
+
+	for range 2 {
+		var result struct{ Value int }
+		if _, err := client.GetJSON(context.Background(), server.URL, &result); err != nil || result.Value != 1 {
+			t.Fatalf("result = %+v, error = %v", result, err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("requests while fresh = %d", requests.Load())
+	}
+
+	now = now.Add(2 * time.Minute)
+	response, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusOK || string(response.Body) != `{"value":1}` || notModified.Load() != 1 {
+		t.Fatalf("response = %+v, not modified = %d", response, notModified.Load())
+	}
+	if _, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 2 {
+		t.Fatalf("requests after revalidation = %d", requests.Load())
+	}
+}
+
+func TestCacheNoStoreVaryAndStaleWhileRevalidate(t *testing.T) {
+	var requests atomic.Int32
+	revalidated := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := requests.Add(1)
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		case "/swr":
+			w.Header().Set("Cache-Control", "max-age=1, stale-while-revalidate=60")
+			if count > 1 {
+				defer func() { revalidated <- struct{}{} }()
+			}
+		}
+		_, _ = io.WriteString(w, strconv.Itoa(int(count)))
+	}))
+	defer server.Close()
+
+	client := New(WithCache(CachePolicy{}))
+	now := time.Now()
+	client.cache.now = func() time.Time { return now }
+	get := func(path, language string) string {
+		t.Helper()
+		response, err := client.Do(context.Background(), http.MethodGet, server.URL+path, nil, http.Header{"Accept-Language": {language}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(response.Body)
+	}
+
+	if get("/private", "en") == get("/private", "en") {
+		t.Fatal("no-store response was cached")
+	}
+	english := get("/vary", "en")
+	german := get("/vary", "de")
+	if german == english || get("/vary", "de") != german {
+		t.Fatal("vary header was not honoured")
+	}
+
+	first := get("/swr", "en")
+	now = now.Add(10 * time.Second)
+	if get("/swr", "en") != first {
+		t.Fatal("stale response was not served while revalidating")
+	}
+	select {
+	case <-revalidated:
+	case <-time.After(time.Second):
+		t.Fatal("background revalidation did not run")
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryCache(2, 10)
+	cache.Set("a", &CacheEntry{Body: []byte("1234")})
+	cache.Set("b", &CacheEntry{Body: []byte("1234")})
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("missing a")
+	}
+	cache.Set("c", &CacheEntry{Body: []byte("1234")})
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("b was not evicted")
+	}
+	cache.Set("d", &CacheEntry{Body: []byte("12345678")})
+	if _, ok := cache.Get("d"); !ok || cache.Len() != 1 {
+		t.Fatalf("byte limit eviction left %d entries", cache.Len())
+	}
+}
+
+func TestDiskCacheRoundTrip(t *testing.T) {
+	cache, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := time.Unix(1700000000, 0).UTC()
+	cache.Set("GET http://example.test", &CacheEntry{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Etag": {`"v1"`}},
+		Body:       []byte("data"),
+		StoredAt:   stored,
+	})
+	entry, ok := cache.Get("GET http://example.test")
+	if !ok || string(entry.Body) != "data" || entry.Header.Get("ETag") != `"v1"` || !entry.StoredAt.Equal(stored) {
+		t.Fatalf("entry = %+v, ok = %v", entry, ok)
+	}
+	cache.Delete("GET http://example.test")
+	if _, ok := cache.Get("GET http://example.test"); ok {
+		t.Fatal("entry was not deleted")
+	}
+}
+
+func TestCacheSkipsRangeCredentialedAndEventStreamRequests(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		switch {
+		case r.URL.Path == "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, "data: one\n\n")
+			w.(http.Flusher).Flush()
+			<-release
+		case r.Header.Get("Range") != "":
+			w.Header().Set("Content-Range", "bytes 2-3/4")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = io.WriteString(w, "cd")
+		default:
+			_, _ = io.WriteString(w, "abcd"+r.Header.Get("Authorization"))
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := New(WithCache(CachePolicy{}))
+	get := func(header http.Header) *Response {
+		t.Helper()
+		response, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+	if string(get(nil).Body) != "abcd" {
+		t.Fatal("unexpected first body")
+	}
+	if response := get(http.Header{"Range": {"bytes=2-"}}); response.StatusCode != http.StatusPartialContent {
+		t.Fatalf("range request answered from cache: %d %q", response.StatusCode, response.Body)
+	}
+	if body := string(get(http.Header{"Authorization": {"Bearer ann"}}).Body); body != "abcdBearer ann" {
+		t.Fatalf("credentialed request body = %q", body)
+	}
+	if body := string(get(http.Header{"Authorization": {"Bearer bob"}}).Body); body != "abcdBearer bob" {
+		t.Fatalf("private response shared between users: %q", body)
+	}
+	if requests.Load() != 4 {
+		t.Fatalf("requests = %d", requests.Load())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.DoStream(ctx, http.MethodGet, server.URL+"/events", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	line := make([]byte, len("data: one\n\n"))
+	if _, err := io.ReadFull(stream.Body, line); err != nil || string(line) != "data: one\n\n" {
+		t.Fatalf("event stream read = %q, %v", line, err)
+	}
+}
+
+func TestCachedResponseKeepsRedirects(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, "moved")
+	}))
+	defer server.Close()
+
+	client := New(WithCache(CachePolicy{}))
+	for range 2 {
+		response, err := client.Do(context.Background(), http.MethodGet, server.URL+"/old", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Redirects) != 1 || response.Redirects[0].StatusCode != http.StatusMovedPermanently {
+			t.Fatalf("redirects = %+v", response.Redirects)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("requests = %d", requests.Load())
+	}
+}
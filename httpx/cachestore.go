@@ -0,0 +1,162 @@
+package httpx
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	defaultMemoryCacheEntries       = 1024
+	defaultMemoryCacheBytes   int64 = 64 << 20
+)
+
+// MemoryCache is a least-recently-used CacheStorage bounded by entry count
+// and total body size. Zero limits use 1024 entries and 64 MiB.
+type MemoryCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	size       int64
+	order      *list.List
+	items      map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+func NewMemoryCache(maxEntries int, maxBytes int64) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultMemoryCacheEntries
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultMemoryCacheBytes
+	}
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (m *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	element, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	m.order.MoveToFront(element)
+	return element.Value.(*memoryCacheItem).entry, true
+}
+
+// Set stores entry, evicting the least recently used entries as needed. An
+// entry larger than the byte limit is not stored.
+func (m *MemoryCache) Set(key string, entry *CacheEntry) {
+	if entry == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.remove(key)
+	if int64(len(entry.Body)) > m.maxBytes {
+		return
+	}
+	m.items[key] = m.order.PushFront(&memoryCacheItem{key: key, entry: entry})
+	m.size += int64(len(entry.Body))
+	for m.order.Len() > m.maxEntries || m.size > m.maxBytes {
+		m.remove(m.order.Back().Value.(*memoryCacheItem).key)
+	}
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.remove(key)
+}
+
+// Len returns the number of stored entries.
+func (m *MemoryCache) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.order.Len()
+}
+
+func (m *MemoryCache) remove(key string) {
+	element, ok := m.items[key]
+	if !ok {
+		return
+	}
+	m.order.Remove(element)
+	delete(m.items, key)
+	m.size -= int64(len(element.Value.(*memoryCacheItem).entry.Body))
+}
+
+// DiskCache stores one JSON file per entry in a directory. Writes are atomic,
+// so concurrent processes may share the directory. It does not evict entries.
+type DiskCache struct {
+	dir string
+}
+
+type diskCacheRecord struct {
+	Key   string      `json:"key"`
+	Entry *CacheEntry `json:"entry"`
+}
+
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if dir == "" {
+		return nil, errors.New("disk cache directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (d *DiskCache) Get(key string) (*CacheEntry, bool) {
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var record diskCacheRecord
+	if err := json.Unmarshal(data, &record); err != nil || record.Key != key || record.Entry == nil {
+		return nil, false
+	}
+	return record.Entry, true
+}
+
+func (d *DiskCache) Set(key string, entry *CacheEntry) {
+	if entry == nil {
+		return
+	}
+	data, err := json.Marshal(diskCacheRecord{Key: key, Entry: entry})
+	if err != nil {
+		return
+	}
+	file, err := os.CreateTemp(d.dir, ".entry-*")
+	if err != nil {
+		return
+	}
+	_, writeErr := file.Write(data)
+	closeErr := file.Close()
+	if writeErr != nil || closeErr != nil || os.Rename(file.Name(), d.path(key)) != nil {
+		_ = os.Remove(file.Name())
+	}
+}
+
+func (d *DiskCache) Delete(key string) {
+	_ = os.Remove(d.path(key))
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
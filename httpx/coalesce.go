@@ -0,0 +1,119 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// WithRequestCoalescing shares one upstream request among concurrent Do
+// calls for the same GET. Calls match when their URL and the values of the
+// named request headers are equal; Authorization and Cookie are always
+// compared so callers with different credentials never share a response.
+// Every caller receives its own copy of the Response and the shared error. A
+// caller whose context ends stops waiting; the shared request is cancelled
+// only when every caller has stopped waiting. The shared request runs on
+// context.WithoutCancel of the first caller's context, so it carries that
+// caller's values, such as trace IDs, but not its deadline or cancellation.
+// Streaming calls and requests with a body are never coalesced, and observers
+// see one request per shared call.
+func WithRequestCoalescing(varyHeaders ...string) Option {
+	return func(client *Client) {
+		vary := []string{"Authorization", "Cookie"}
+		for _, name := range varyHeaders {
+			vary = append(vary, http.CanonicalHeaderKey(name))
+		}
+		slices.Sort(vary)
+		client.coalescer = &coalescer{vary: slices.Compact(vary), calls: make(map[string]*coalescedCall)}
+	}
+}
+
+type coalescer struct {
+	vary []string
+
+	mutex sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done     chan struct{}
+	waiters  int
+	cancel   context.CancelFunc
+	response *Response
+	err      error
+}
+
+// coalescingKey identifies a GET by URL and the values of the vary headers.
+func coalescingKey(url string, header http.Header, vary []string) string {
+	var key strings.Builder
+	key.WriteString(url)
+	for _, name := range vary {
+		key.WriteString("\n" + name + ":")
+		key.WriteString(strings.Join(header.Values(name), "\x00"))
+	}
+	return key.String()
+}
+
+// do joins the call in flight for key or starts one with fetch. The shared
+// call is detached from the first caller's cancellation with
+// context.WithoutCancel and cancelled only when every waiter has left.
+func (c *coalescer) do(
+	ctx context.Context,
+	key string,
+	fetch func(context.Context) (*Response, error),
+) (*Response, error) {
+	c.mutex.Lock()
+	call, ok := c.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &coalescedCall{done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = call
+		go func() {
+			call.response, call.err = fetch(callCtx)
+			c.forget(key, call)
+			close(call.done)
+			cancel()
+		}()
+	}
+	call.waiters++
+	c.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.response.clone(), call.err
+	case <-ctx.Done():
+		c.mutex.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+		}
+		c.mutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (c *coalescer) forget(key string, call *coalescedCall) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+}
+
+// clone returns a copy that shares no mutable state with r.
+func (r *Response) clone() *Response {
+	if r == nil {
+		return nil
+	}
+	return &Response{
+		StatusCode: r.StatusCode,
+		Header:     r.Header.Clone(),
+		Body:       slices.Clone(r.Body),
+		Redirects:  slices.Clone(r.Redirects),
+	}
+}
@@ -0,0 +1,97 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestCoalescingSharesConcurrentGets(t *testing.T) {
+	var requests atomic.Int32
+	arrived := make(chan struct{}, 2)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		arrived <- struct{}{}
+		<-release
+		w.Header().Set("X-User", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte("shared"))
+	}))
+	defer server.Close()
+
+	client := New(WithRequestCoalescing("Accept"))
+	abandoned, abandon := context.WithCancel(context.Background())
+	abandonedErr := make(chan error, 1)
+	go func() {
+		_, err := client.Do(abandoned, http.MethodGet, server.URL, nil, http.Header{"Authorization": {"a"}})
+		abandonedErr <- err
+	}()
+
+	var group sync.WaitGroup
+	responses := make([]*Response, 8)
+	for index := range responses {
+		group.Go(func() {
+			header := http.Header{"Authorization": {"a"}}
+			if index == len(responses)-1 {
+				header.Set("Authorization", "b")
+			}
+			response, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, header)
+			if err != nil {
+				t.Error(err)
+			}
+			responses[index] = response
+		})
+	}
+	for range 2 {
+		<-arrived
+	}
+	waitForWaiters(t, client.coalescer, len(responses)+1)
+	abandon()
+	if err := <-abandonedErr; err != context.Canceled {
+		t.Fatalf("abandoned caller err = %v", err)
+	}
+	close(release)
+	group.Wait()
+
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("upstream requests = %d, want 2", got)
+	}
+	for index, response := range responses {
+		want := "a"
+		if index == len(responses)-1 {
+			want = "b"
+		}
+		if response == nil || string(response.Body) != "shared" || response.Header.Get("X-User") != want {
+			t.Fatalf("response %d = %+v", index, response)
+		}
+	}
+	responses[0].Body[0] = 'X'
+	if string(responses[1].Body) != "shared" {
+		t.Fatal("coalesced callers share a body slice")
+	}
+}
+
+// waitForWaiters blocks until want callers are waiting on shared calls.
+func waitForWaiters(t *testing.T, c *coalescer, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mutex.Lock()
+		waiters := 0
+		for _, call := range c.calls {
+			waiters += call.waiters
+		}
+		c.mutex.Unlock()
+		if waiters == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("waiters = %d, want %d", waiters, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
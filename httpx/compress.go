@@ -0,0 +1,204 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultCompressionMinBytes int64 = 1024
+
+// CompressionPolicy configures request body compression. Encoding is "gzip",
+// the default, or "deflate". Bodies shorter than MinBytes, which defaults to
+// 1 KiB, are sent unchanged. Level is a compress/flate level; zero uses the
+// default level.
+type CompressionPolicy struct {
+	Encoding string
+	MinBytes int64
+	Level    int
+}
+
+// WithRequestCompression compresses request bodies of at least
+// policy.MinBytes and sets Content-Encoding. Compression is applied to every
+// attempt as the body is streamed, so the uncompressed body is never
+// buffered beyond MinBytes. Requests that already carry a Content-Encoding are
+// sent unchanged. Signers see the compressed body.
+func WithRequestCompression(policy CompressionPolicy) Option {
+	return func(client *Client) {
+		policy.Encoding = strings.ToLower(policy.Encoding)
+		if policy.Encoding == "" {
+			policy.Encoding = "gzip"
+		}
+		if policy.MinBytes <= 0 {
+			policy.MinBytes = defaultCompressionMinBytes
+		}
+		if policy.Level == 0 {
+			policy.Level = flate.DefaultCompression
+		}
+		client.compression = &policy
+	}
+}
+
+// WithResponseDecompression asks for gzip and deflate responses and decodes
+// them. The Content-Encoding and Content-Length headers are removed from
+// decoded responses. Limits such as StreamResponse.LimitBody and the client
+// body limit apply to the decoded bytes, so a small compressed body cannot
+// expand past them.
+func WithResponseDecompression() Option {
+	return func(client *Client) {
+		client.decompress = true
+	}
+}
+
+// compressRequest decides whether the attempt body is compressed. It reads up
+// to MinBytes of body; shorter bodies are sent as read.
+func (c *Client) compressRequest(spec Request, body io.ReadCloser) (Request, io.ReadCloser, error) {
+	policy := c.compression
+	if spec.Header.Get("Content-Encoding") != "" || (spec.ContentLength > 0 && spec.ContentLength < policy.MinBytes) {
+		return spec, body, nil
+	}
+	if policy.Encoding != "gzip" && policy.Encoding != "deflate" {
+		_ = body.Close()
+		return spec, nil, fmt.Errorf("unsupported request content encoding %q", policy.Encoding)
+	}
+	prefix := make([]byte, policy.MinBytes)
+	count, err := io.ReadFull(body, prefix)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		_ = body.Close()
+		spec.ContentLength = int64(count)
+		return spec, io.NopCloser(bytes.NewReader(prefix[:count])), nil
+	}
+	if err != nil {
+		_ = body.Close()
+		return spec, nil, err
+	}
+
+	original := spec.Body
+	spec.Body = func() (io.ReadCloser, error) {
+		reader, err := original()
+		if err != nil {
+			return nil, err
+		}
+		return compressStream(reader, policy), nil
+	}
+	spec.Header = spec.Header.Clone()
+	if spec.Header == nil {
+		spec.Header = make(http.Header)
+	}
+	spec.Header.Set("Content-Encoding", policy.Encoding)
+	spec.ContentLength = -1
+	rest := &prefixedReadCloser{reader: io.MultiReader(bytes.NewReader(prefix), body), body: body}
+	return spec, compressStream(rest, policy), nil
+}
+
+// compressStream compresses source through a pipe. Closing the returned
+// reader stops the writer and closes source.
+func compressStream(source io.ReadCloser, policy *CompressionPolicy) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		defer source.Close()
+		var encoder io.WriteCloser
+		var err error
+		if policy.Encoding == "deflate" {
+			encoder, err = zlib.NewWriterLevel(writer, policy.Level)
+		} else {
+			encoder, err = gzip.NewWriterLevel(writer, policy.Level)
+		}
+		if err != nil {
+			_ = writer.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(encoder, source); err != nil {
+			_ = writer.CloseWithError(err)
+			return
+		}
+		_ = writer.CloseWithError(encoder.Close())
+	}()
+	return reader
+}
+
+// prefixedReadCloser reads the bytes already taken from body before the rest
+// of body.
+type prefixedReadCloser struct {
+	reader io.Reader
+	body   io.ReadCloser
+}
+
+func (r *prefixedReadCloser) Read(target []byte) (int, error) {
+	return r.reader.Read(target)
+}
+
+func (r *prefixedReadCloser) Close() error {
+	return r.body.Close()
+}
+
+// decompressResponse replaces a gzip or deflate body with a decoding reader.
+func decompressResponse(response *http.Response) {
+	encoding := strings.ToLower(strings.TrimSpace(response.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "gzip", "x-gzip", "deflate":
+	default:
+		return
+	}
+	if response.Body == nil || response.Body == http.NoBody {
+		return
+	}
+	response.Body = &decodingReadCloser{body: response.Body, encoding: encoding}
+	response.Header.Del("Content-Encoding")
+	response.Header.Del("Content-Length")
+	response.ContentLength = -1
+	response.Uncompressed = true
+}
+
+// decodingReadCloser starts decoding on the first Read so that opening a
+// response does not block on the body.
+type decodingReadCloser struct {
+	body     io.ReadCloser
+	encoding string
+	reader   io.Reader
+	err      error
+}
+
+func (r *decodingReadCloser) Read(target []byte) (int, error) {
+	if r.reader == nil && r.err == nil {
+		r.reader, r.err = newDecoder(r.body, r.encoding)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.reader.Read(target)
+}
+
+func (r *decodingReadCloser) Close() error {
+	if closer, ok := r.reader.(io.Closer); ok {
+		_ = closer.Close()
+	}
+	return r.body.Close()
+}
+
+// newDecoder accepts both zlib-wrapped and raw deflate streams, since servers
+// send either for "deflate".
+func newDecoder(body io.Reader, encoding string) (io.Reader, error) {
+	if encoding != "deflate" {
+		return gzip.NewReader(body)
+	}
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(2)
+	if err != nil && len(header) < 2 {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return flate.NewReader(buffered), nil
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
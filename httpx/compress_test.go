@@ -0,0 +1,111 @@
+package httpx
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestCompressionAppliesPerAttempt(t *testing.T) {
+	large := strings.Repeat(`{"slide":"content"},`, 500)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ = io.ReadAll(reader)
+		} else {
+			body, _ = io.ReadAll(r.Body)
+		}
+		if r.URL.Path == "/large" && requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, r.Header.Get("Content-Encoding")+":"+string(body))
+	}))
+	defer server.Close()
+
+	client := New(
+		WithRequestCompression(CompressionPolicy{}),
+		WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, Methods: []string{http.MethodPost}}),
+	)
+	response, err := client.Do(context.Background(), http.MethodPost, server.URL+"/large", strings.NewReader(large), nil)
+	if err != nil || string(response.Body) != "gzip:"+large || requests.Load() != 2 {
+		t.Fatalf("response = %.40q, error = %v, requests = %d", response.Body, err, requests.Load())
+	}
+	response, err = client.Do(context.Background(), http.MethodPost, server.URL+"/small", strings.NewReader("tiny"), nil)
+	if err != nil || string(response.Body) != ":tiny" {
+		t.Fatalf("response = %q, error = %v", response.Body, err)
+	}
+}
+
+func TestResponseDecompression(t *testing.T) {
+	bomb := make([]byte, 1<<20)
+	encode := func(encoding string, data []byte) []byte {
+		var buffer bytes.Buffer
+		var writer io.WriteCloser
+		switch encoding {
+		case "gzip":
+			writer = gzip.NewWriter(&buffer)
+		case "zlib":
+			writer = zlib.NewWriter(&buffer)
+		default:
+			writer, _ = flate.NewWriter(&buffer, flate.BestCompression)
+		}
+		_, _ = writer.Write(data)
+		_ = writer.Close()
+		return buffer.Bytes()
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip, deflate" {
+			t.Errorf("accept-encoding = %q", r.Header.Get("Accept-Encoding"))
+		}
+		encoding := r.URL.Query().Get("encoding")
+		data := []byte("hello " + encoding)
+		if r.URL.Path == "/bomb" {
+			data = bomb
+		}
+		if encoding == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+		} else {
+			w.Header().Set("Content-Encoding", "deflate")
+		}
+		_, _ = w.Write(encode(encoding, data))
+	}))
+	defer server.Close()
+
+	client := New(WithResponseDecompression(), WithMaxBodyBytes(64<<10))
+	for _, encoding := range []string{"gzip", "zlib", "raw"} {
+		response, err := client.Do(context.Background(), http.MethodGet, server.URL+"/?encoding="+encoding, nil, nil)
+		if err != nil || string(response.Body) != "hello "+encoding || response.Header.Get("Content-Encoding") != "" {
+			t.Fatalf("%s: response = %+v, error = %v", encoding, response, err)
+		}
+	}
+
+	_, err := client.Do(context.Background(), http.MethodGet, server.URL+"/bomb?encoding=gzip", nil, nil)
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("buffered bomb error = %v", err)
+	}
+	stream, err := client.DoStream(context.Background(), http.MethodGet, server.URL+"/bomb?encoding=zlib", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	stream.LimitBody(128 << 10)
+	if _, err := io.Copy(io.Discard, stream.Body); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("stream bomb error = %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package httpx
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Curl renders request as an equivalent POSIX shell curl command. Headers
+// and the URL are redacted as described by Redaction, HEAD requests use
+// --head, and a GET with a body keeps -X GET. The body is read from a fresh copy of request.Body, redacted, and
+// quoted inline; a body longer than MaxBodyBytes after redaction is cut to
+// that length, a binary body is replaced by --data-binary @body.bin, and a
+// body that cannot be redacted is left out, each with a shell comment on the
+// first line saying so. Client default headers are not included because the
+// request has not been through a client.
+func Curl(request Request, redaction Redaction) (string, error) {
+	method := request.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var comment string
+	arguments := []string{""}
+	for _, name := range sortedKeys(request.Header) {
+		for _, value := range request.Header[name] {
+			if redaction.header(name) {
+				value = redactedValue
+			}
+			arguments = append(arguments, "-H "+shellQuote(name+": "+value))
+		}
+	}
+
+	if request.Body != nil && redaction.bodyLimit() >= 0 {
+		body, err := request.Body()
+		if err != nil {
+			return "", err
+		}
+		// Reading one byte past the capture limit shows whether the body was
+		// read whole.
+		data, err := io.ReadAll(io.LimitReader(body, redaction.captureLimit()+1))
+		closeErr := body.Close()
+		if err != nil {
+			return "", err
+		}
+		if closeErr != nil {
+			return "", closeErr
+		}
+		size := int64(len(data))
+		if size > redaction.captureLimit() {
+			data = data[:redaction.captureLimit()]
+		}
+		data, truncated, ok := redaction.prepareBody(request.Header.Get("Content-Type"), data, size)
+		if truncated {
+			comment = fmt.Sprintf("# request body truncated to %d bytes\n", len(data))
+		}
+		switch {
+		case !ok:
+			comment = "# request body omitted because it could not be redacted\n"
+		case !utf8.Valid(data):
+			comment = "# binary request body omitted; save it as body.bin\n"
+			arguments = append(arguments, "--data-binary @body.bin")
+		case len(data) > 0:
+			arguments = append(arguments, "--data-binary "+shellQuote(string(data)))
+		}
+	}
+
+	// curl sends --data-binary as POST unless told otherwise.
+	command := "curl "
+	switch {
+	case method == http.MethodHead:
+		command += "--head "
+	case method != http.MethodGet || strings.HasPrefix(arguments[len(arguments)-1], "--data-binary "):
+		command += "-X " + shellQuote(method) + " "
+	}
+	arguments[0] = command + shellQuote(RedactURL(request.URL))
+	return comment + strings.Join(arguments, " \\\n  "), nil
+}
+
+// shellQuote quotes value for a POSIX shell. Words made only of safe
+// characters are left bare.
+func shellQuote(value string) string {
+	if value != "" && strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:@=,+%", r))
+	}) < 0 {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,395 @@
+package httpx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMinPartSize int64 = 8 << 20
+
+var (
+	ErrDownloadSizeMismatch = errors.New("downloaded size does not match expected size")
+	ErrChecksumMismatch     = errors.New("downloaded content does not match checksum")
+	ErrDownloadChanged      = errors.New("resource changed during download")
+)
+
+// DownloadOptions configures Download. Parallelism above one splits the body
+// into that many byte ranges when the server supports ranges and the body is
+// at least two MinPartSize parts long; MinPartSize defaults to 8 MiB.
+// ExpectedSize, when positive, must match the downloaded size. Checksum is an
+// expected hex digest computed with Hash, which defaults to SHA-256. FileMode
+// defaults to 0644.
+type DownloadOptions struct {
+	Header       http.Header
+	Parallelism  int
+	MinPartSize  int64
+	ExpectedSize int64
+	Hash         func() hash.Hash
+	Checksum     string
+	FileMode     os.FileMode
+}
+
+// DownloadResult describes a completed download. Checksum is the hex digest
+// when Hash or Checksum was set. Resumes counts ranges requested again after
+// an interrupted body.
+type DownloadResult struct {
+	Size     int64
+	Checksum string
+	Parts    int
+	Resumes  int
+}
+
+// Download writes the body of a GET request to path. Data is written to a
+// temporary file in the same directory, which is renamed into place only
+// after the size and checksum have been verified, so path never holds a
+// partial file.
+//
+// When reading the body fails and the retry policy allows GET requests and
+// transport-error retries, the remaining bytes are requested with Range and
+// If-Range, so a changed resource restarts the download instead of mixing
+// versions. Each part may be resumed MaxAttempts-1 times; OnRetry is called
+// for every resume.
+func (c *Client) Download(ctx context.Context, url, path string, options DownloadOptions) (*DownloadResult, error) {
+	if c == nil {
+		c = New()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if options.MinPartSize <= 0 {
+		options.MinPartSize = defaultMinPartSize
+	}
+	if options.FileMode == 0 {
+		options.FileMode = 0o644
+	}
+	if options.Hash == nil && options.Checksum != "" {
+		options.Hash = sha256.New
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".download-*")
+	if err != nil {
+		return nil, err
+	}
+	transfer := &download{
+		client:  c,
+		url:     url,
+		options: options,
+		policy:  c.retry(),
+		file:    file,
+		total:   -1,
+	}
+	result, err := transfer.run(ctx)
+	if syncErr := file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), options.FileMode)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return nil, err
+	}
+	return result, nil
+}
+
+type download struct {
+	client  *Client
+	url     string
+	options DownloadOptions
+	policy  RetryPolicy
+	file    *os.File
+
+	mutex     sync.Mutex
+	total     int64
+	validator string
+	resumes   int
+}
+
+// downloadPart is the byte range [start, end]; end is -1 when the size is not
+// known yet. Digest, when set, receives the bytes in order.
+type downloadPart struct {
+	start    int64
+	end      int64
+	written  int64
+	parallel bool
+	digest   hash.Hash
+}
+
+func (d *download) run(ctx context.Context) (*DownloadResult, error) {
+	parts := []*downloadPart{{end: -1}}
+	if d.options.Parallelism > 1 {
+		var err error
+		if parts, err = d.split(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if len(parts) == 1 && d.options.Hash != nil {
+		parts[0].digest = d.options.Hash()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var group sync.WaitGroup
+	errs := make([]error, len(parts))
+	for index, part := range parts {
+		group.Go(func() {
+			if errs[index] = d.fetch(ctx, part); errs[index] != nil {
+				cancel()
+			}
+		})
+	}
+	group.Wait()
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var size int64
+	for _, part := range parts {
+		size += part.written
+	}
+	if (d.total >= 0 && size != d.total) || (d.options.ExpectedSize > 0 && size != d.options.ExpectedSize) {
+		return nil, fmt.Errorf("%w: got %d bytes", ErrDownloadSizeMismatch, size)
+	}
+	result := &DownloadResult{Size: size, Parts: len(parts), Resumes: d.resumes}
+	if d.options.Hash != nil {
+		digest := parts[0].digest
+		if digest == nil {
+			digest = d.options.Hash()
+			if _, err := io.Copy(digest, io.NewSectionReader(d.file, 0, size)); err != nil {
+				return nil, err
+			}
+		}
+		result.Checksum = hex.EncodeToString(digest.Sum(nil))
+		if d.options.Checksum != "" && !strings.EqualFold(result.Checksum, d.options.Checksum) {
+			return nil, fmt.Errorf("%w: got %s", ErrChecksumMismatch, result.Checksum)
+		}
+	}
+	return result, nil
+}
+
+// split probes the size with a one-byte range and divides the body into
+// parts. It returns a single open-ended part when ranges are not supported or
+// the body is too small to split.
+func (d *download) split(ctx context.Context) ([]*downloadPart, error) {
+	response, err := d.request(ctx, 0, 0, false)
+	if err != nil {
+		return nil, err
+	}
+	drainAndClose(response.Body)
+	first, _, total, ok := parseContentRange(response.Header.Get("Content-Range"))
+	if response.StatusCode != http.StatusPartialContent || !ok || first != 0 || total < 2*d.options.MinPartSize {
+		return []*downloadPart{{end: -1}}, nil
+	}
+	d.total = total
+	d.validator = rangeValidator(response.Header)
+	count := min(int64(d.options.Parallelism), total/d.options.MinPartSize)
+	size := (total + count - 1) / count
+	parts := make([]*downloadPart, 0, count)
+	for start := int64(0); start < total; start += size {
+		parts = append(parts, &downloadPart{start: start, end: min(start+size, total) - 1, parallel: true})
+	}
+	return parts, nil
+}
+
+// fetch downloads part, resuming after interrupted bodies within the retry
+// policy.
+func (d *download) fetch(ctx context.Context, part *downloadPart) error {
+	var delay time.Duration
+	for resume := 1; ; resume++ {
+		err := d.read(ctx, part)
+		if err == nil {
+			return nil
+		}
+		var interrupted *interruptedBodyError
+		if !errors.As(err, &interrupted) || ctx.Err() != nil || resume >= d.policy.MaxAttempts ||
+			!d.policy.RetryTransportErrors || !d.policy.allowsMethod(http.MethodGet) ||
+			(d.client.budget != nil && !d.client.budget.withdraw()) {
+			if interrupted != nil {
+				return interrupted.err
+			}
+			return err
+		}
+		delay = d.policy.delay(resume, delay, nil)
+		if d.policy.OnRetry != nil {
+			d.policy.OnRetry(RetryEvent{
+				Attempt:     resume,
+				NextAttempt: resume + 1,
+				Method:      http.MethodGet,
+				URL:         d.url,
+				Err:         interrupted.err,
+				Delay:       delay,
+			})
+		}
+		d.mutex.Lock()
+		d.resumes++
+		d.mutex.Unlock()
+		if err := waitForRetry(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// read requests the unread remainder of part and copies it into the file.
+func (d *download) read(ctx context.Context, part *downloadPart) error {
+	start := part.start + part.written
+	if part.end >= 0 && start > part.end {
+		return nil
+	}
+	response, err := d.request(ctx, start, part.end, part.parallel || part.written > 0)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusPartialContent:
+		first, _, total, ok := parseContentRange(response.Header.Get("Content-Range"))
+		if !ok || first != start {
+			return fmt.Errorf("unexpected Content-Range %q for offset %d", response.Header.Get("Content-Range"), start)
+		}
+		if !part.parallel && total >= 0 {
+			d.total = total
+		}
+	case http.StatusOK:
+		if part.parallel {
+			return ErrDownloadChanged
+		}
+		if part.written > 0 {
+			if err := d.file.Truncate(0); err != nil {
+				return err
+			}
+			part.written = 0
+			if part.digest != nil {
+				part.digest.Reset()
+			}
+		}
+		d.total = response.ContentLength
+		// A full response replaces the file, so later resumes must validate
+		// against this representation.
+		d.validator = rangeValidator(response.Header)
+	case http.StatusRequestedRangeNotSatisfiable:
+		if !part.parallel && part.written > 0 && part.written == d.total {
+			return nil
+		}
+		return response.CheckStatus(0)
+	default:
+		return response.CheckStatus(0)
+	}
+	if d.validator == "" && !part.parallel {
+		d.validator = rangeValidator(response.Header)
+	}
+
+	var target io.Writer = io.NewOffsetWriter(d.file, part.start+part.written)
+	if part.digest != nil {
+		target = io.MultiWriter(target, part.digest)
+	}
+	if part.end >= 0 {
+		response.Body = &limitedReadCloser{body: response.Body, remaining: part.end - part.start - part.written + 1}
+	}
+	count, err := io.Copy(target, response.Body)
+	part.written += count
+	var pathErr *os.PathError
+	switch {
+	case errors.Is(err, ErrBodyTooLarge), errors.As(err, &pathErr):
+		return err
+	case err != nil:
+		return &interruptedBodyError{err: err}
+	case part.end >= 0 && part.start+part.written <= part.end:
+		return &interruptedBodyError{err: io.ErrUnexpectedEOF}
+	}
+	return nil
+}
+
+func (d *download) request(ctx context.Context, start, end int64, ranged bool) (*StreamResponse, error) {
+	header := d.options.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Accept-Encoding", "identity")
+	if ranged || start > 0 || end >= 0 {
+		value := "bytes=" + strconv.FormatInt(start, 10) + "-"
+		if end >= 0 {
+			value += strconv.FormatInt(end, 10)
+		}
+		header.Set("Range", value)
+		if d.validator != "" {
+			header.Set("If-Range", d.validator)
+		}
+	}
+	return d.client.observe(ctx, Request{Method: http.MethodGet, URL: d.url, Header: header}, d.client.doWithRetry)
+}
+
+// rangeValidator returns a strong ETag or, failing that, Last-Modified for
+// If-Range.
+func rangeValidator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+// parseContentRange parses "bytes first-last/total"; total is -1 when the
+// server sent "*".
+func parseContentRange(value string) (int64, int64, int64, bool) {
+	rangeSpec, found := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !found {
+		return 0, 0, 0, false
+	}
+	span, size, found := strings.Cut(rangeSpec, "/")
+	firstText, lastText, ok := strings.Cut(span, "-")
+	if !found || !ok {
+		return 0, 0, 0, false
+	}
+	first, err := strconv.ParseInt(firstText, 10, 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	last, err := strconv.ParseInt(lastText, 10, 64)
+	if err != nil || last < first {
+		return 0, 0, 0, false
+	}
+	total := int64(-1)
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil || total <= last {
+			return 0, 0, 0, false
+		}
+	}
+	return first, last, total, true
+}
+
+// interruptedBodyError marks a body that failed after the response arrived
+// and can be resumed with a range request.
+type interruptedBodyError struct {
+	err error
+}
+
+func (e *interruptedBodyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *interruptedBodyError) Unwrap() error {
+	return e.err
+}
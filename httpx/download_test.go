@@ -0,0 +1,196 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func downloadContent(size int) []byte {
+	content := make([]byte, size)
+	for index := range content {
+		content[index] = byte(index % 251)
+	}
+	return content
+}
+
+func TestDownloadResumesInterruptedBody(t *testing.T) {
+	content := downloadContent(64 << 10)
+	var requests atomic.Int32
+	var mutex sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		ranges = append(ranges, r.Header.Get("Range")+"|"+r.Header.Get("If-Range"))
+		mutex.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:20000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256(content)
+	var retries atomic.Int32
+	client := New(WithRetry(RetryPolicy{
+		MaxAttempts:          2,
+		BaseDelay:            time.Millisecond,
+		RetryTransportErrors: true,
+		OnRetry:              func(RetryEvent) { retries.Add(1) },
+	}))
+	path := filepath.Join(t.TempDir(), "artifact.bin")
+	result, err := client.Download(context.Background(), server.URL, path, DownloadOptions{
+		Checksum: hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !bytes.Equal(data, content) || result.Size != int64(len(content)) || result.Resumes != 1 || retries.Load() != 1 {
+		t.Fatalf("result = %+v, retries = %d, size = %d", result, retries.Load(), len(data))
+	}
+	if len(ranges) != 2 || ranges[0] != "|" || ranges[1] != `bytes=20000-|"v1"` {
+		t.Fatalf("ranges = %q", ranges)
+	}
+}
+
+func TestDownloadResumeAfterRestartUsesNewValidator(t *testing.T) {
+	content := downloadContent(64 << 10)
+	var requests atomic.Int32
+	var mutex sync.Mutex
+	var statuses []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := requests.Add(1)
+		etag := `"v2"`
+		if count == 1 {
+			etag = `"v1"`
+		}
+		w.Header().Set("ETag", etag)
+		status := http.StatusOK
+		if r.Header.Get("Range") != "" && r.Header.Get("If-Range") == etag {
+			status = http.StatusPartialContent
+		}
+		mutex.Lock()
+		statuses = append(statuses, status)
+		mutex.Unlock()
+		if count <= 2 {
+			// The first body and the restarted body are both cut short.
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:20000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	client := New(WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, RetryTransportErrors: true}))
+	path := filepath.Join(t.TempDir(), "artifact.bin")
+	result, err := client.Download(context.Background(), server.URL, path, DownloadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !bytes.Equal(data, content) || result.Resumes != 2 {
+		t.Fatalf("result = %+v, size = %d", result, len(data))
+	}
+	if len(statuses) != 3 || statuses[1] != http.StatusOK || statuses[2] != http.StatusPartialContent {
+		t.Fatalf("statuses = %v", statuses)
+	}
+}
+
+func TestDownloadResumeDrawsFromRetryBudget(t *testing.T) {
+	content := downloadContent(64 << 10)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		_, _ = w.Write(content[:20000])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	client := New(
+		WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, RetryTransportErrors: true}),
+		WithRetryBudget(RetryBudget{Ratio: -1, MinRetries: -1}),
+	)
+	path := filepath.Join(t.TempDir(), "artifact.bin")
+	if _, err := client.Download(context.Background(), server.URL, path, DownloadOptions{}); err == nil {
+		t.Fatal("interrupted download succeeded")
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("requests = %d, want no resumes once the budget is spent", requests.Load())
+	}
+}
+
+func TestDownloadParallelRanges(t *testing.T) {
+	content := downloadContent(10000)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "artifact.bin")
+	result, err := New().Download(context.Background(), server.URL, path, DownloadOptions{
+		Parallelism: 4,
+		MinPartSize: 1000,
+		Hash:        sha256.New,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	data, _ := os.ReadFile(path)
+	if !bytes.Equal(data, content) || result.Parts != 4 || result.Checksum != hex.EncodeToString(sum[:]) {
+		t.Fatalf("result = %+v, size = %d", result, len(data))
+	}
+	if requests.Load() != 5 {
+		t.Fatalf("requests = %d", requests.Load())
+	}
+}
+
+func TestDownloadVerificationFailureLeavesNoFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifact.bin")
+	tests := []struct {
+		options DownloadOptions
+		want    error
+	}{
+		{DownloadOptions{Checksum: "00"}, ErrChecksumMismatch},
+		{DownloadOptions{ExpectedSize: 3}, ErrDownloadSizeMismatch},
+	}
+	for _, test := range tests {
+		_, err := New().Download(context.Background(), server.URL, path, test.options)
+		if !errors.Is(err, test.want) {
+			t.Fatalf("error = %v, want %v", err, test.want)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Fatalf("directory = %v", entries)
+	}
+}
@@ -0,0 +1,504 @@
+package httpx
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultCaptureBodyBytes int64 = 64 << 10
+	maxRedactableBodyBytes  int64 = 8 << 20
+)
+
+// defaultRedactedHeaders are always hidden by Redaction.
+var defaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+	"X-Amz-Security-Token",
+}
+
+// Redaction controls what HARRecorder and Curl reveal. Authorization,
+// Proxy-Authorization, Cookie, Set-Cookie, X-Api-Key, X-Auth-Token, and
+// X-Amz-Security-Token values are always hidden; Headers adds more names,
+// matched case-insensitively. URLs are passed through RedactURL. Body, when
+// set, rewrites each complete body, for example with RedactJSONFields, before
+// it is cut to MaxBodyBytes; a body is left out when Body returns nil or when
+// it is larger than 8 MiB and so cannot be redacted whole. MaxBodyBytes limits
+// how much of each body is kept; zero keeps 64 KiB and a negative value omits
+// bodies.
+type Redaction struct {
+	Headers      []string
+	Body         func(contentType string, body []byte) []byte
+	MaxBodyBytes int64
+}
+
+func (r Redaction) header(name string) bool {
+	return slices.ContainsFunc(defaultRedactedHeaders, func(candidate string) bool {
+		return strings.EqualFold(candidate, name)
+	}) || slices.ContainsFunc(r.Headers, func(candidate string) bool {
+		return strings.EqualFold(candidate, name)
+	})
+}
+
+func (r Redaction) bodyLimit() int64 {
+	if r.MaxBodyBytes == 0 {
+		return defaultCaptureBodyBytes
+	}
+	return r.MaxBodyBytes
+}
+
+// headers returns header as name/value pairs in a stable order, with
+// redacted values hidden.
+func (r Redaction) headers(header http.Header) []HARNameValue {
+	pairs := []HARNameValue{}
+	for _, name := range sortedKeys(header) {
+		for _, value := range header[name] {
+			if r.header(name) {
+				value = redactedValue
+			}
+			pairs = append(pairs, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+// captureLimit returns how much of a body to keep before redaction. A body
+// redactor needs the whole body, so more is kept when one is set.
+func (r Redaction) captureLimit() int64 {
+	if r.Body != nil {
+		return max(r.bodyLimit(), maxRedactableBodyBytes)
+	}
+	return r.bodyLimit()
+}
+
+// prepareBody redacts data, the captured start of a body of size bytes, and
+// then cuts it to the body limit. It reports whether the result is shorter
+// than the body, and returns ok false when the body must be left out because
+// a body redactor is set and data is incomplete or was rejected.
+func (r Redaction) prepareBody(contentType string, data []byte, size int64) (kept []byte, truncated, ok bool) {
+	if r.Body != nil && len(data) > 0 {
+		if int64(len(data)) < size {
+			return nil, false, false
+		}
+		if data = r.Body(contentType, data); data == nil {
+			return nil, false, false
+		}
+	}
+	if limit := r.bodyLimit(); int64(len(data)) > limit {
+		return data[:limit], true, true
+	}
+	return data, int64(len(data)) < size, true
+}
+
+// RedactJSONFields returns a Redaction.Body function that hides the values of
+// JSON object members with the given names, matched case-insensitively at any
+// depth. Bodies whose Content-Type names another media type are returned
+// unchanged; other bodies that are not valid JSON yield nil, so they are left
+// out rather than shown unredacted.
+func RedactJSONFields(names ...string) func(contentType string, body []byte) []byte {
+	return func(contentType string, body []byte) []byte {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil &&
+			mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			return body
+		}
+		var document any
+		if err := json.Unmarshal(body, &document); err != nil {
+			return nil
+		}
+		redacted, err := json.Marshal(redactJSON(document, names))
+		if err != nil {
+			return nil
+		}
+		return redacted
+	}
+}
+
+func redactJSON(value any, names []string) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, member := range typed {
+			if slices.ContainsFunc(names, func(name string) bool { return strings.EqualFold(name, key) }) {
+				typed[key] = redactedValue
+				continue
+			}
+			typed[key] = redactJSON(member, names)
+		}
+	case []any:
+		for index, element := range typed {
+			typed[index] = redactJSON(element, names)
+		}
+	}
+	return value
+}
+
+// HAR is an HTTP Archive 1.2 document.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is one request attempt. Time and the timings are in milliseconds.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARContent is a response body. Bodies that are not valid UTF-8 are base64
+// encoded. Size counts every byte read from the response, including bytes
+// beyond the captured Text.
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARTimings splits an entry's time. Send is not measured separately and is
+// always zero; Wait ends when the response headers arrive and Receive when the
+// body is read to the end or closed, and both are zero until then. Blocked,
+// DNS, Connect, and SSL are not measured and are -1.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARRecorder captures request attempts for export as a HAR file. Install
+// Intercept with WithInterceptor; as an interceptor it records requests
+// before signers run, so signatures added by signers are not captured. Bodies
+// are captured as they are sent and read, so streamed bodies appear as far as
+// they were consumed. A HARRecorder is safe for concurrent use.
+type HARRecorder struct {
+	redaction Redaction
+
+	mutex   sync.Mutex
+	entries []*harCapture
+}
+
+// NewHARRecorder returns a recorder that applies redaction to every entry.
+func NewHARRecorder(redaction Redaction) *HARRecorder {
+	return &HARRecorder{redaction: redaction}
+}
+
+type harCapture struct {
+	entry    HAREntry
+	request  capturedBody
+	response capturedBody
+	headers  time.Time
+	done     time.Time
+}
+
+type capturedBody struct {
+	contentType string
+	data        []byte
+	size        int64
+}
+
+// Intercept is an Interceptor that records the attempt.
+func (r *HARRecorder) Intercept(request *http.Request, next Sender) (*http.Response, error) {
+	capture := &harCapture{entry: HAREntry{
+		StartedDateTime: time.Now(),
+		Request:         r.harRequest(request),
+	}}
+	capture.request.contentType = request.Header.Get("Content-Type")
+	if request.Body != nil && request.Body != http.NoBody {
+		request.Body = &capturingReadCloser{body: request.Body, recorder: r, target: &capture.request}
+	}
+	r.mutex.Lock()
+	r.entries = append(r.entries, capture)
+	r.mutex.Unlock()
+
+	response, err := next(request)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	capture.headers = time.Now()
+	if err != nil {
+		capture.entry.Comment = err.Error()
+		capture.done = capture.headers
+		return response, err
+	}
+	capture.entry.Response = r.harResponse(response)
+	capture.response.contentType = response.Header.Get("Content-Type")
+	if response.Body == nil || response.Body == http.NoBody {
+		capture.done = capture.headers
+		return response, nil
+	}
+	response.Body = &capturingReadCloser{
+		body:     response.Body,
+		recorder: r,
+		target:   &capture.response,
+		finish:   func(now time.Time) { capture.done = now },
+	}
+	return response, nil
+}
+
+func (r *HARRecorder) harRequest(request *http.Request) HARRequest {
+	rawURL := request.URL.String()
+	redacted, _ := url.Parse(RedactURL(rawURL))
+	query := []HARNameValue{}
+	if redacted != nil {
+		for _, name := range sortedKeys(redacted.Query()) {
+			for _, value := range redacted.Query()[name] {
+				query = append(query, HARNameValue{Name: name, Value: value})
+			}
+		}
+	}
+	cookies := []HARCookie{}
+	for _, cookie := range request.Cookies() {
+		cookies = append(cookies, r.cookie(cookie, "Cookie"))
+	}
+	return HARRequest{
+		Method:      request.Method,
+		URL:         RedactURL(rawURL),
+		HTTPVersion: request.Proto,
+		Cookies:     cookies,
+		Headers:     r.redaction.headers(request.Header),
+		QueryString: query,
+		HeadersSize: -1,
+	}
+}
+
+func (r *HARRecorder) harResponse(response *http.Response) HARResponse {
+	cookies := []HARCookie{}
+	for _, cookie := range response.Cookies() {
+		cookies = append(cookies, r.cookie(cookie, "Set-Cookie"))
+	}
+	redirect := response.Header.Get("Location")
+	if redirect != "" {
+		redirect = RedactURL(redirect)
+	}
+	return HARResponse{
+		Status:      response.StatusCode,
+		StatusText:  http.StatusText(response.StatusCode),
+		HTTPVersion: response.Proto,
+		Cookies:     cookies,
+		Headers:     r.redaction.headers(response.Header),
+		RedirectURL: redirect,
+		HeadersSize: -1,
+	}
+}
+
+func (r *HARRecorder) cookie(cookie *http.Cookie, header string) HARCookie {
+	value := cookie.Value
+	if r.redaction.header(header) {
+		value = redactedValue
+	}
+	return HARCookie{Name: cookie.Name, Value: value}
+}
+
+// HAR returns a snapshot of the captured entries in the order their attempts
+// started.
+func (r *HARRecorder) HAR() HAR {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	entries := make([]HAREntry, 0, len(r.entries))
+	for _, capture := range r.entries {
+		entries = append(entries, r.finishEntry(capture))
+	}
+	return HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "httpx", Version: "1"},
+		Entries: entries,
+	}}
+}
+
+// WriteTo writes the captured entries as an indented HAR document.
+func (r *HARRecorder) WriteTo(writer io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	count, err := writer.Write(append(data, '\n'))
+	return int64(count), err
+}
+
+// Reset discards the captured entries.
+func (r *HARRecorder) Reset() {
+	r.mutex.Lock()
+	r.entries = nil
+	r.mutex.Unlock()
+}
+
+// finishEntry fills in bodies and timings. It must be called with the mutex
+// held.
+func (r *HARRecorder) finishEntry(capture *harCapture) HAREntry {
+	entry := capture.entry
+	entry.Timings = HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	if !capture.headers.IsZero() {
+		entry.Timings.Wait = milliseconds(capture.headers.Sub(entry.StartedDateTime))
+		end := capture.headers
+		if !capture.done.IsZero() {
+			end = capture.done
+			entry.Timings.Receive = milliseconds(capture.done.Sub(capture.headers))
+		}
+		entry.Time = milliseconds(end.Sub(entry.StartedDateTime))
+	}
+	if capture.request.size > 0 {
+		text, _, comment := r.bodyText(capture.request, false)
+		entry.Request.BodySize = capture.request.size
+		entry.Request.PostData = &HARPostData{
+			MimeType: capture.request.contentType,
+			Text:     text,
+			Comment:  comment,
+		}
+	}
+	if entry.Response.Headers == nil {
+		entry.Response.Cookies, entry.Response.Headers = []HARCookie{}, []HARNameValue{}
+	}
+	if !capture.headers.IsZero() && entry.Comment == "" {
+		text, encoding, comment := r.bodyText(capture.response, true)
+		entry.Response.BodySize = capture.response.size
+		entry.Response.Content = HARContent{
+			Size:     capture.response.size,
+			MimeType: capture.response.contentType,
+			Text:     text,
+			Encoding: encoding,
+			Comment:  comment,
+		}
+	}
+	return entry
+}
+
+// bodyText applies body redaction and describes truncation or omission. Binary bodies are
+// base64 encoded when allowBase64 is set and omitted otherwise, since HAR
+// post data has no encoding field.
+func (r *HARRecorder) bodyText(body capturedBody, allowBase64 bool) (string, string, string) {
+	if r.redaction.bodyLimit() < 0 {
+		return "", "", "body omitted"
+	}
+	data, truncated, ok := r.redaction.prepareBody(body.contentType, body.data, body.size)
+	if !ok {
+		return "", "", fmt.Sprintf("body of %d bytes omitted because it could not be redacted", body.size)
+	}
+	var comment string
+	if truncated {
+		comment = fmt.Sprintf("body truncated to %d of %d bytes", len(data), body.size)
+	}
+	if utf8.Valid(data) {
+		return string(data), "", comment
+	}
+	if allowBase64 {
+		return base64.StdEncoding.EncodeToString(data), "base64", comment
+	}
+	return "", "", fmt.Sprintf("binary body of %d bytes omitted", body.size)
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+// capturingReadCloser copies what passes through it into target, up to the
+// recorder's body limit.
+type capturingReadCloser struct {
+	body     io.ReadCloser
+	recorder *HARRecorder
+	target   *capturedBody
+	finish   func(time.Time)
+	finished bool
+}
+
+func (c *capturingReadCloser) Read(data []byte) (int, error) {
+	count, err := c.body.Read(data)
+	c.recorder.mutex.Lock()
+	defer c.recorder.mutex.Unlock()
+	c.target.size += int64(count)
+	if room := c.recorder.redaction.captureLimit() - int64(len(c.target.data)); room > 0 {
+		c.target.data = append(c.target.data, data[:min(int64(count), room)]...)
+	}
+	if errors.Is(err, io.EOF) {
+		c.done()
+	}
+	return count, err
+}
+
+func (c *capturingReadCloser) Close() error {
+	err := c.body.Close()
+	c.recorder.mutex.Lock()
+	c.done()
+	c.recorder.mutex.Unlock()
+	return err
+}
+
+// done must be called with the recorder mutex held.
+func (c *capturingReadCloser) done() {
+	if c.finished || c.finish == nil {
+		return
+	}
+	c.finished = true
+	c.finish(time.Now())
+}
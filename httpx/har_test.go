@@ -0,0 +1,170 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHARRecorderCapturesRedactedExchange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cret"})
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	recorder := NewHARRecorder(Redaction{
+		Headers:      []string{"X-Partner-Token"},
+		Body:         RedactJSONFields("password"),
+		MaxBodyBytes: 64,
+	})
+	client := New(WithInterceptor(recorder.Intercept))
+	header := http.Header{
+		"Authorization":   {"Bearer abc"},
+		"X-Partner-Token": {"partner"},
+		"Content-Type":    {"application/json"},
+	}
+	body := strings.NewReader(`{"user":"ann","password":"hunter2"}`)
+	if _, err := client.Do(context.Background(), http.MethodPost, server.URL+"/login?api_key=k&v=1", body, header); err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	if _, err := recorder.WriteTo(&output); err != nil {
+		t.Fatal(err)
+	}
+	var document HAR
+	if err := json.Unmarshal(output.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.Log.Version != "1.2" || len(document.Log.Entries) != 1 {
+		t.Fatalf("log = %+v", document.Log)
+	}
+	entry := document.Log.Entries[0]
+	if entry.Request.URL != server.URL+"/login?api_key=REDACTED&v=1" || entry.Request.HTTPVersion != "HTTP/1.1" {
+		t.Fatalf("request = %+v", entry.Request)
+	}
+	for _, header := range entry.Request.Headers {
+		if (header.Name == "Authorization" || header.Name == "X-Partner-Token") && header.Value != redactedValue {
+			t.Fatalf("header %s = %q", header.Name, header.Value)
+		}
+	}
+	if entry.Request.PostData == nil || entry.Request.PostData.Text != `{"password":"REDACTED","user":"ann"}` ||
+		entry.Request.BodySize != 35 {
+		t.Fatalf("post data = %+v, size %d", entry.Request.PostData, entry.Request.BodySize)
+	}
+	content := entry.Response.Content
+	if entry.Response.Status != http.StatusOK || content.Size != 100 || content.Text != strings.Repeat("x", 64) ||
+		content.Comment != "body truncated to 64 of 100 bytes" || content.MimeType != "text/plain" {
+		t.Fatalf("response = %+v", entry.Response)
+	}
+	if len(entry.Response.Cookies) != 1 || entry.Response.Cookies[0].Value != redactedValue {
+		t.Fatalf("cookies = %+v", entry.Response.Cookies)
+	}
+	if entry.Time <= 0 || entry.Timings.Wait < 0 || entry.Timings.Receive < 0 {
+		t.Fatalf("timings = %+v, time %v", entry.Timings, entry.Time)
+	}
+	if strings.Contains(output.String(), "hunter2") || strings.Contains(output.String(), "s3cret") {
+		t.Fatalf("secret leaked: %s", output.String())
+	}
+}
+
+func TestCurlRendersRequest(t *testing.T) {
+	request := Request{
+		Method: http.MethodPut,
+		URL:    "https://api.example.com/items/1?token=t",
+		Header: http.Header{
+			"Authorization": {"Bearer abc"},
+			"Content-Type":  {"application/json"},
+		},
+		Body: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(`{"name":"it's"}`)), nil
+		},
+	}
+	command, err := Curl(request, Redaction{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "curl -X PUT 'https://api.example.com/items/1?token=REDACTED' \\\n" +
+		"  -H 'Authorization: REDACTED' \\\n  -H 'Content-Type: application/json' \\\n" +
+		`  --data-binary '{"name":"it'\''s"}'`
+	if command != want {
+		t.Fatalf("command =\n%s\nwant\n%s", command, want)
+	}
+
+	command, err = Curl(request, Redaction{MaxBodyBytes: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(command, "# request body truncated to 4 bytes\n") ||
+		!strings.HasSuffix(command, `--data-binary '{"na'`) {
+		t.Fatalf("truncated command =\n%s", command)
+	}
+}
+
+func TestRedactionRunsBeforeTruncation(t *testing.T) {
+	body := `{"password":"hunter2","z":"` + strings.Repeat("p", 100) + `"}`
+	request := Request{
+		Method: http.MethodPost,
+		URL:    "https://api.example.com/login",
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(body)), nil
+		},
+	}
+	redaction := Redaction{Body: RedactJSONFields("password"), MaxBodyBytes: 32}
+	command, err := Curl(request, redaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(command, "hunter2") || !strings.Contains(command, `REDACTED`) ||
+		!strings.HasPrefix(command, "# request body truncated to 32 bytes\n") {
+		t.Fatalf("command =\n%s", command)
+	}
+
+	request.Body = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`{"password":"hunter2"`)), nil
+	}
+	command, err = Curl(request, redaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(command, "hunter2") || strings.Contains(command, "--data-binary") ||
+		!strings.HasPrefix(command, "# request body omitted because it could not be redacted\n") {
+		t.Fatalf("unparsable body command =\n%s", command)
+	}
+}
+
+func TestCurlRendersHead(t *testing.T) {
+	command, err := Curl(Request{Method: http.MethodHead, URL: "https://example.com/"}, Redaction{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command != "curl --head https://example.com/" {
+		t.Fatalf("command = %s", command)
+	}
+}
+
+func TestCurlKeepsGetWithBody(t *testing.T) {
+	request := Request{
+		Method: http.MethodGet,
+		URL:    "https://example.com/search",
+		Body: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(`{"q":"go"}`)), nil
+		},
+	}
+	command, err := Curl(request, Redaction{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "curl -X GET https://example.com/search \\\n  --data-binary '{\"q\":\"go\"}'"; command != want {
+		t.Fatalf("command =\n%s\nwant\n%s", command, want)
+	}
+}
@@ -0,0 +1,218 @@
+package httpx
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHedgeMinSamples = 20
+	hedgeLatencyWindow     = 256
+)
+
+// HedgePolicy configures hedged requests. When an attempt has not returned
+// response headers after Delay, a second attempt is sent alongside it.
+// Percentile, between 0 and 1, replaces Delay with that percentile of the
+// client's recent time to response headers once MinSamples, which defaults to
+// 20, have been seen; Delay is used until then, and a zero Delay then means no
+// hedging. Methods defaults to GET and HEAD and should list only idempotent
+// methods.
+type HedgePolicy struct {
+	Delay      time.Duration
+	Percentile float64
+	MinSamples int
+	Methods    []string
+}
+
+// WithHedging sends a hedged attempt for slow requests allowed by policy.
+// The first response that the retry policy would not retry wins; the other
+// attempt is cancelled and its body drained. A hedged attempt takes the next
+// attempt number, counts toward RetryPolicy.MaxAttempts, and is reported to
+// RetryPolicy.OnRetry with RetryEvent.Hedge set. One hedge is allowed even
+// when retries are disabled. Policies with neither Delay nor Percentile are
+// ignored.
+func WithHedging(policy HedgePolicy) Option {
+	return func(client *Client) {
+		if policy.Percentile <= 0 || policy.Percentile > 1 {
+			policy.Percentile = 0
+		}
+		if policy.Delay <= 0 && policy.Percentile == 0 {
+			client.hedge = nil
+			return
+		}
+		if policy.MinSamples <= 0 {
+			policy.MinSamples = defaultHedgeMinSamples
+		}
+		if len(policy.Methods) == 0 {
+			policy.Methods = []string{http.MethodGet, http.MethodHead}
+		} else {
+			policy.Methods = append([]string(nil), policy.Methods...)
+		}
+		client.hedge = &hedger{policy: policy}
+	}
+}
+
+// hedger holds a hedge policy and a window of recent latencies.
+type hedger struct {
+	policy HedgePolicy
+
+	mutex   sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (h *hedger) allowsMethod(method string) bool {
+	for _, allowed := range h.policy.Methods {
+		if strings.EqualFold(method, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *hedger) record(latency time.Duration) {
+	if h.policy.Percentile == 0 {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.samples) < hedgeLatencyWindow {
+		h.samples = append(h.samples, latency)
+		return
+	}
+	h.samples[h.next] = latency
+	h.next = (h.next + 1) % hedgeLatencyWindow
+}
+
+// delay returns how long to wait before hedging, or false when no delay is
+// known yet.
+func (h *hedger) delay() (time.Duration, bool) {
+	if h.policy.Percentile > 0 {
+		h.mutex.Lock()
+		samples := slices.Clone(h.samples)
+		h.mutex.Unlock()
+		if len(samples) >= h.policy.MinSamples {
+			slices.Sort(samples)
+			index := int(math.Ceil(h.policy.Percentile*float64(len(samples)))) - 1
+			return samples[max(index, 0)], true
+		}
+	}
+	return h.policy.Delay, h.policy.Delay > 0
+}
+
+type hedgeResult struct {
+	response *StreamResponse
+	err      error
+	attempt  int
+}
+
+// hedgedAttempt runs attempt and, when it is slow, attempt+1 alongside it.
+// It returns the chosen result and the last attempt number used.
+func (c *Client) hedgedAttempt(
+	ctx context.Context,
+	request Request,
+	attempt int,
+	policy RetryPolicy,
+) (*StreamResponse, int, error) {
+	delay, ok := c.hedge.delay()
+	if !ok {
+		started := time.Now()
+		response, err := c.runAttempt(ctx, request, attempt)
+		if err == nil {
+			c.hedge.record(time.Since(started))
+		}
+		return response, attempt, err
+	}
+
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	launch := func(number int) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func() {
+			started := time.Now()
+			response, err := c.runAttempt(attemptCtx, request, number)
+			if err == nil {
+				c.hedge.record(time.Since(started))
+			}
+			results <- hedgeResult{response: response, err: err, attempt: number}
+		}()
+	}
+	launch(attempt)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	last, pending := attempt, 1
+	var fallback *hedgeResult
+	for {
+		select {
+		case <-timer.C:
+			if ctx.Err() != nil || (c.budget != nil && !c.budget.withdraw()) {
+				continue
+			}
+			if policy.OnRetry != nil {
+				policy.OnRetry(RetryEvent{
+					Attempt:     attempt,
+					NextAttempt: attempt + 1,
+					Method:      request.Method,
+					URL:         request.URL,
+					Delay:       delay,
+					Hedge:       true,
+				})
+			}
+			last++
+			pending++
+			launch(last)
+		case result := <-results:
+			pending--
+			final := result.err == nil && !policy.retryable(RetryOutcome{
+				Attempt:    result.attempt,
+				Method:     request.Method,
+				URL:        request.URL,
+				StatusCode: result.response.StatusCode,
+				Header:     result.response.Header,
+			})
+			if !final && pending > 0 {
+				if fallback != nil {
+					discardHedge(*fallback, attempt, cancels)
+				}
+				fallback = &result
+				continue
+			}
+			if fallback != nil {
+				discardHedge(*fallback, attempt, cancels)
+			}
+			for index, cancel := range cancels {
+				if index != result.attempt-attempt {
+					cancel()
+				}
+			}
+			go func(pending int) {
+				for range pending {
+					discardHedge(<-results, attempt, cancels)
+				}
+			}(pending)
+			cancel := cancels[result.attempt-attempt]
+			if result.response == nil || result.response.Body == nil {
+				cancel()
+			} else {
+				result.response.Body = &releaseOnClose{body: result.response.Body, release: cancel}
+			}
+			return result.response, last, result.err
+		}
+	}
+}
+
+// discardHedge drains and closes a losing attempt's response and cancels the
+// attempt. first is the attempt number of cancels[0].
+func discardHedge(result hedgeResult, first int, cancels []context.CancelFunc) {
+	if result.response != nil {
+		drainAndClose(result.response.Body)
+	}
+	cancels[result.attempt-first]()
+}
@@ -0,0 +1,120 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHedgedRequestWinsAndCancelsSlowAttempt(t *testing.T) {
+	canceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Attempt") == "1" {
+			select {
+			case <-r.Context().Done():
+				close(canceled)
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("hedge"))
+	}))
+	defer server.Close()
+
+	var events []RetryEvent
+	client := New(
+		WithHedging(HedgePolicy{Delay: 20 * time.Millisecond}),
+		WithRetry(RetryPolicy{OnRetry: func(event RetryEvent) { events = append(events, event) }}),
+		WithRequestMutator(func(request *http.Request) error {
+			attempt, _ := AttemptFromContext(request.Context())
+			request.Header.Set("X-Attempt", strconv.Itoa(attempt))
+			return nil
+		}),
+	)
+	started := time.Now()
+	response, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil || string(response.Body) != "hedge" {
+		t.Fatalf("response = %+v, err = %v", response, err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("hedged request took %v", elapsed)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("slow attempt was not cancelled")
+	}
+	if len(events) != 1 || !events[0].Hedge || events[0].Attempt != 1 || events[0].NextAttempt != 2 ||
+		events[0].Delay != 20*time.Millisecond {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func TestHedgedAttemptsCountTowardRetries(t *testing.T) {
+	var mutex sync.Mutex
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := r.Header.Get("X-Attempt")
+		mutex.Lock()
+		seen = append(seen, attempt)
+		mutex.Unlock()
+		switch attempt {
+		case "1":
+			time.Sleep(100 * time.Millisecond)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "2":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	var events []RetryEvent
+	client := New(
+		WithHedging(HedgePolicy{Delay: 20 * time.Millisecond}),
+		WithRetry(RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+			OnRetry:     func(event RetryEvent) { events = append(events, event) },
+		}),
+		WithRequestMutator(func(request *http.Request) error {
+			attempt, _ := AttemptFromContext(request.Context())
+			request.Header.Set("X-Attempt", strconv.Itoa(attempt))
+			return nil
+		}),
+	)
+	response, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil || string(response.Body) != "ok" {
+		t.Fatalf("response = %+v, err = %v", response, err)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(seen) != 3 || seen[2] != "3" {
+		t.Fatalf("attempts = %q", seen)
+	}
+	if len(events) != 2 || !events[0].Hedge || events[1].Hedge || events[1].Attempt != 2 ||
+		events[1].NextAttempt != 3 || events[1].StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func TestHedgerPercentileDelay(t *testing.T) {
+	hedge := &hedger{policy: HedgePolicy{Delay: time.Second, Percentile: 0.9, MinSamples: 10}}
+	for index := 1; index <= 9; index++ {
+		hedge.record(time.Duration(index) * time.Millisecond)
+	}
+	if delay, ok := hedge.delay(); !ok || delay != time.Second {
+		t.Fatalf("delay before enough samples = %v, %v", delay, ok)
+	}
+	for index := 10; index <= 100; index++ {
+		hedge.record(time.Duration(index) * time.Millisecond)
+	}
+	if delay, ok := hedge.delay(); !ok || delay != 90*time.Millisecond {
+		t.Fatalf("delay = %v, %v", delay, ok)
+	}
+}
@@ -24,12 +24,31 @@ type Client struct {
 	maxBodyBytes      int64
 	retryPolicy       RetryPolicy
 	requestValidators []RequestValidator
+	requestMutators   []RequestMutator
+	interceptors      []Interceptor
+	responseObservers []ResponseObserver
+	breaker           *circuitBreaker
+	limiter           *rateLimiter
+	cache             *responseCache
+	tokens            *CachingTokenSource
+	signers           []Signer
+	ssrf              *SSRFGuard
+	redirects         *RedirectPolicy
+	errorDecoder      ErrorDecoder
+	compression       *CompressionPolicy
+	decompress        bool
+	observers         []Observer
+	idempotency       *IdempotencyPolicy
+	hedge             *hedger
+	coalescer         *coalescer
+	budget            *retryBudget
 }
 
 type Response struct {
 	StatusCode int
 	Header     http.Header
 	Body       []byte
+	Redirects  []Redirect
 }
 
 type StatusError struct {
@@ -56,6 +75,14 @@ func New(options ...Option) *Client {
 			option(client)
 		}
 	}
+	if client.redirects != nil {
+		copy := *client.httpClient
+		copy.CheckRedirect = client.checkRedirect
+		client.httpClient = &copy
+	}
+	if client.ssrf != nil {
+		client.httpClient = client.ssrf.client(client.httpClient)
+	}
 	return client
 }
 
@@ -91,7 +118,7 @@ func WithHeader(key, value string) Option {
 
 // WithRetry enables retry behavior for requests allowed by policy.
 // MaxAttempts includes the initial request. A value smaller than two disables
-// retries.
+// retries. WithIdempotencyKeys extends retries to requests that carry a key.
 func WithRetry(policy RetryPolicy) Option {
 	return func(client *Client) {
 		client.retryPolicy = policy.clone()
@@ -100,6 +127,8 @@ func WithRetry(policy RetryPolicy) Option {
 
 // WithRequestValidator adds a validator that runs before every request
 // attempt. It can enforce policies such as allowed URL schemes or hosts.
+// Clients with interceptors or signers run validators twice per attempt, so
+// a validator must not count calls or otherwise depend on running once.
 func WithRequestValidator(validator RequestValidator) Option {
 	return func(client *Client) {
 		if validator != nil {
@@ -128,6 +157,21 @@ func (c *Client) Do(
 	url string,
 	body io.Reader,
 	headers http.Header,
+) (*Response, error) {
+	if c.coalescer != nil && body == nil && (method == http.MethodGet || method == "") {
+		return c.coalescer.do(ctx, coalescingKey(url, headers, c.coalescer.vary), func(ctx context.Context) (*Response, error) {
+			return c.doBuffered(ctx, method, url, nil, headers)
+		})
+	}
+	return c.doBuffered(ctx, method, url, body, headers)
+}
+
+func (c *Client) doBuffered(
+	ctx context.Context,
+	method string,
+	url string,
+	body io.Reader,
+	headers http.Header,
 ) (*Response, error) {
 	stream, err := c.DoStream(ctx, method, url, body, headers)
 	if err != nil {
@@ -141,6 +185,7 @@ func (c *Client) Do(
 		StatusCode: stream.StatusCode,
 		Header:     stream.Header.Clone(),
 		Body:       data,
+		Redirects:  stream.Redirects,
 	}
 	if err != nil {
 		return result, err
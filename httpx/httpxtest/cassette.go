@@ -0,0 +1,400 @@
+// Package httpxtest provides helpers for testing code that uses httpx.
+package httpxtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/iEvan-lhr/exciting-tool/httpx"
+)
+
+const (
+	defaultMaxBodyBytes int64 = 8 << 20
+	redactedValue             = "REDACTED"
+	boundaryPlaceholder       = "HTTPXTEST-BOUNDARY"
+)
+
+var (
+	ErrNoInteraction = errors.New("no recorded interaction matches request")
+	ErrBodyTooLarge  = errors.New("recorded body exceeds configured limit")
+)
+
+type Mode uint8
+
+const (
+	// ModeAuto replays an existing cassette and records a missing one.
+	ModeAuto Mode = iota
+	// ModeReplay serves only recorded interactions and never uses the network.
+	ModeReplay
+	// ModeRecord sends every request and replaces the cassette on Close.
+	ModeRecord
+)
+
+// Cassette is the file format written by a Recorder.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body"`
+}
+
+// RecordedResponse stores a response. Truncated reports that the client closed
+// a streamed body before reaching its end, or that the body exceeded the
+// recorder limit, so only part of it was kept.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body"`
+	Truncated  bool        `json:"truncated,omitempty"`
+}
+
+// Body is stored as text when it is valid UTF-8 and as base64 otherwise.
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(struct {
+			Text string `json:"text"`
+		}{string(b)})
+	}
+	return json.Marshal(struct {
+		Base64 string `json:"base64"`
+	}{base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var value struct {
+		Text   *string `json:"text"`
+		Base64 *string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch {
+	case value.Base64 != nil:
+		decoded, err := base64.StdEncoding.DecodeString(*value.Base64)
+		if err != nil {
+			return err
+		}
+		*b = decoded
+	case value.Text != nil:
+		*b = Body(*value.Text)
+	default:
+		*b = nil
+	}
+	return nil
+}
+
+// RecorderOptions configures a Recorder. Requests always match on method and
+// URL; MatchHeaders adds header fields and IgnoreBody skips the body.
+// Multipart boundaries are normalized so replayed uploads match. Headers in
+// RedactHeaders are written as "REDACTED"; Authorization, Cookie, Set-Cookie,
+// and Proxy-Authorization are always redacted. MaxBodyBytes bounds each
+// recorded body and defaults to 8 MiB.
+type RecorderOptions struct {
+	Mode          Mode
+	Transport     http.RoundTripper
+	MatchHeaders  []string
+	IgnoreBody    bool
+	RedactHeaders []string
+	MaxBodyBytes  int64
+}
+
+// Recorder is an http.RoundTripper that records interactions to a cassette
+// file or replays them. Close must be called to save a recording.
+type Recorder struct {
+	mutex        sync.Mutex
+	path         string
+	mode         Mode
+	options      RecorderOptions
+	interactions []*Interaction
+	used         []bool
+	pending      sync.WaitGroup
+}
+
+func NewRecorder(path string, options RecorderOptions) (*Recorder, error) {
+	if path == "" {
+		return nil, errors.New("cassette path cannot be empty")
+	}
+	if options.MaxBodyBytes <= 0 {
+		options.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if options.Transport == nil {
+		options.Transport = http.DefaultTransport
+	}
+	options.RedactHeaders = append(options.RedactHeaders,
+		"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization")
+	recorder := &Recorder{path: path, mode: options.Mode, options: options}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil && recorder.mode != ModeRecord:
+		var cassette Cassette
+		if err := json.Unmarshal(data, &cassette); err != nil {
+			return nil, fmt.Errorf("read cassette %s: %w", path, err)
+		}
+		for index := range cassette.Interactions {
+			recorder.interactions = append(recorder.interactions, &cassette.Interactions[index])
+		}
+		recorder.used = make([]bool, len(recorder.interactions))
+		recorder.mode = ModeReplay
+	case errors.Is(err, os.ErrNotExist) && recorder.mode == ModeAuto:
+		recorder.mode = ModeRecord
+	case err != nil && recorder.mode == ModeReplay:
+		return nil, err
+	}
+	return recorder, nil
+}
+
+// Recording reports whether requests are sent to the network.
+func (r *Recorder) Recording() bool {
+	return r.mode == ModeRecord
+}
+
+// Client returns an http.Client that uses the recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Option configures an httpx.Client to use the recorder.
+func (r *Recorder) Option() httpx.Option {
+	return httpx.WithHTTPClient(r.Client())
+}
+
+func (r *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(request, r.options.MaxBodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	recorded := RecordedRequest{
+		Method: request.Method,
+		URL:    request.URL.String(),
+		Header: request.Header.Clone(),
+		Body:   body,
+	}
+	normalizeMultipart(&recorded)
+	if r.mode != ModeRecord {
+		return r.replay(request, recorded)
+	}
+
+	outgoing := request.Clone(request.Context())
+	outgoing.Body = io.NopCloser(bytes.NewReader(body))
+	outgoing.ContentLength = int64(len(body))
+	if len(body) == 0 && request.Body == nil {
+		outgoing.Body = nil
+	}
+	response, err := r.options.Transport.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := &Interaction{Request: recorded}
+	interaction.Request.Header = r.redact(interaction.Request.Header)
+	interaction.Response = RecordedResponse{
+		StatusCode: response.StatusCode,
+		Header:     r.redact(response.Header.Clone()),
+	}
+	r.mutex.Lock()
+	r.interactions = append(r.interactions, interaction)
+	r.mutex.Unlock()
+	r.pending.Add(1)
+	response.Body = &recordingBody{
+		body:        response.Body,
+		recorder:    r,
+		interaction: interaction,
+	}
+	return response, nil
+}
+
+// Close saves a recording. It waits until every recorded response body has
+// been read to the end or closed. In replay mode it does nothing.
+func (r *Recorder) Close() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.pending.Wait()
+	r.mutex.Lock()
+	cassette := Cassette{Interactions: make([]Interaction, 0, len(r.interactions))}
+	for _, interaction := range r.interactions {
+		cassette.Interactions = append(cassette.Interactions, *interaction)
+	}
+	r.mutex.Unlock()
+
+	data, err := json.MarshalIndent(cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(r.path), ".cassette-*")
+	if err != nil {
+		return err
+	}
+	_, writeErr := file.Write(append(data, '\n'))
+	closeErr := file.Close()
+	if err := errors.Join(writeErr, closeErr); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), r.path); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
+
+func (r *Recorder) replay(request *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for index, interaction := range r.interactions {
+		if r.used[index] || !r.matches(interaction.Request, recorded) {
+			continue
+		}
+		r.used[index] = true
+		body := append([]byte(nil), interaction.Response.Body...)
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       request,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, recorded.Method, recorded.URL)
+}
+
+func (r *Recorder) matches(stored, actual RecordedRequest) bool {
+	if !strings.EqualFold(stored.Method, actual.Method) || stored.URL != actual.URL {
+		return false
+	}
+	for _, field := range r.options.MatchHeaders {
+		want := strings.Join(stored.Header.Values(field), ",")
+		got := strings.Join(actual.Header.Values(field), ",")
+		if r.redacted(field) {
+			if (want == "") != (got == "") {
+				return false
+			}
+			continue
+		}
+		if want != got {
+			return false
+		}
+	}
+	return r.options.IgnoreBody || bytes.Equal(stored.Body, actual.Body)
+}
+
+func (r *Recorder) redacted(field string) bool {
+	for _, candidate := range r.options.RedactHeaders {
+		if strings.EqualFold(candidate, field) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Recorder) redact(header http.Header) http.Header {
+	for key, values := range header {
+		if r.redacted(key) {
+			for index := range values {
+				values[index] = redactedValue
+			}
+		}
+	}
+	return header
+}
+
+func readRequestBody(request *http.Request, limit int64) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+	defer request.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(request.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+	return data, nil
+}
+
+// normalizeMultipart replaces the random multipart boundary so identical forms
+// produce identical recordings.
+func normalizeMultipart(request *RecordedRequest) {
+	contentType := request.Header.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	boundary := params["boundary"]
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || boundary == "" {
+		return
+	}
+	request.Header.Set("Content-Type", strings.Replace(contentType, boundary, boundaryPlaceholder, 1))
+	request.Body = bytes.ReplaceAll(request.Body, []byte(boundary), []byte(boundaryPlaceholder))
+}
+
+// recordingBody captures a streamed response while the client reads it.
+type recordingBody struct {
+	body        io.ReadCloser
+	recorder    *Recorder
+	interaction *Interaction
+	buffer      bytes.Buffer
+	finished    bool
+	overflowed  bool
+	once        sync.Once
+}
+
+func (b *recordingBody) Read(target []byte) (int, error) {
+	count, err := b.body.Read(target)
+	if count > 0 {
+		if int64(b.buffer.Len()+count) <= b.recorder.options.MaxBodyBytes {
+			b.buffer.Write(target[:count])
+		} else {
+			b.overflowed = true
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		b.finished = true
+		b.finish()
+	}
+	return count, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.body.Close()
+	b.finish()
+	return err
+}
+
+func (b *recordingBody) finish() {
+	b.once.Do(func() {
+		b.recorder.mutex.Lock()
+		b.interaction.Response.Body = append(Body(nil), b.buffer.Bytes()...)
+		b.interaction.Response.Truncated = !b.finished || b.overflowed
+		b.recorder.mutex.Unlock()
+		b.recorder.pending.Done()
+	})
+}
@@ -0,0 +1,133 @@
+package httpxtest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iEvan-lhr/exciting-tool/httpx"
+)
+
+func TestRecorderRecordsAndReplays(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upload":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			_, _ = io.WriteString(w, "uploaded "+r.FormValue("name"))
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, data := range []string{"one", "two"} {
+				_, _ = io.WriteString(w, "data: "+data+"\n\n")
+				w.(http.Flusher).Flush()
+			}
+		default:
+			w.Header().Set("Set-Cookie", "session=secret")
+			_, _ = io.WriteString(w, "hello "+r.Header.Get("X-Tenant"))
+		}
+	}))
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	exercise := func(recorder *Recorder) {
+		t.Helper()
+		client := httpx.New(recorder.Option(), httpx.WithHeader("Authorization", "Bearer token"))
+		response, err := client.Do(context.Background(), http.MethodGet, server.URL+"/hello", nil,
+			http.Header{"X-Tenant": {"acme"}})
+		if err != nil || string(response.Body) != "hello acme" {
+			t.Fatalf("hello response = %+v, error = %v", response, err)
+		}
+
+		form := httpx.NewMultipart()
+		if err := form.AddField("name", "deck"); err != nil {
+			t.Fatal(err)
+		}
+		if err := form.AddBytes("file", "deck.pptx", []byte("pptx")); err != nil {
+			t.Fatal(err)
+		}
+		upload, err := client.PostMultipart(context.Background(), server.URL+"/upload", form, nil)
+		if err != nil || string(upload.Body) != "uploaded deck" {
+			t.Fatalf("upload response = %+v, error = %v", upload, err)
+		}
+
+		stream, err := client.OpenEventStream(context.Background(), httpx.Request{URL: server.URL + "/events"},
+			httpx.EventStreamOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data []string
+		for {
+			event, err := stream.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data = append(data, event.Data)
+		}
+		_ = stream.Close()
+		if strings.Join(data, ",") != "one,two" {
+			t.Fatalf("events = %q", data)
+		}
+	}
+
+	recorder, err := NewRecorder(path, RecorderOptions{MatchHeaders: []string{"X-Tenant", "Authorization"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !recorder.Recording() {
+		t.Fatal("missing cassette did not start recording")
+	}
+	exercise(recorder)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "Bearer token") || strings.Contains(string(data), "session=secret") {
+		t.Fatalf("cassette was not redacted:\n%s", data)
+	}
+
+	replayer, err := NewRecorder(path, RecorderOptions{MatchHeaders: []string{"X-Tenant", "Authorization"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayer.Recording() {
+		t.Fatal("existing cassette did not replay")
+	}
+	exercise(replayer)
+}
+
+func TestRecorderReplayMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := os.WriteFile(path, []byte(`{"interactions":[{"request":{"method":"GET","url":"http://example.test/a","body":{}},"response":{"status_code":200,"body":{"base64":"AAE="}}}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	recorder, err := NewRecorder(path, RecorderOptions{Mode: ModeReplay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := httpx.New(recorder.Option())
+	response, err := client.Do(context.Background(), http.MethodGet, "http://example.test/a", nil, nil)
+	if err != nil || string(response.Body) != "\x00\x01" {
+		t.Fatalf("response = %+v, error = %v", response, err)
+	}
+	if _, err := client.Do(context.Background(), http.MethodGet, "http://example.test/a", nil, nil); !errors.Is(err, ErrNoInteraction) {
+		t.Fatalf("reused interaction error = %v", err)
+	}
+	if _, err := NewRecorder(filepath.Join(t.TempDir(), "missing.json"), RecorderOptions{Mode: ModeReplay}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing cassette error = %v", err)
+	}
+}
@@ -0,0 +1,430 @@
+package httpxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iEvan-lhr/exciting-tool/httpx"
+)
+
+// Server is an in-process stub server. Requests are matched against
+// expectations in registration order; a request that matches none receives
+// 501 Not Implemented and is reported by AssertExpectations.
+type Server struct {
+	URL string
+
+	mutex        sync.Mutex
+	server       *httptest.Server
+	expectations []*Expectation
+	unexpected   []string
+}
+
+// NewServer starts a stub server that is closed when the test finishes.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	stub := &Server{}
+	stub.server = httptest.NewServer(http.HandlerFunc(stub.serve))
+	stub.URL = stub.server.URL
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func (s *Server) Close() {
+	s.server.CloseClientConnections()
+	s.server.Close()
+}
+
+// Client returns an httpx.Client configured with options. It is a convenience
+// for tests that do not need a custom transport.
+func (s *Server) Client(options ...httpx.Option) *httpx.Client {
+	return httpx.New(append([]httpx.Option{httpx.WithHTTPClient(s.server.Client())}, options...)...)
+}
+
+// Expect registers an expectation. Pattern is a path in which "{name}" matches
+// one segment and a final "{name...}" matches the remainder; matched values
+// are available through Request.PathValue in RespondFunc handlers.
+func (s *Server) Expect(method, pattern string) *Expectation {
+	expectation := &Expectation{
+		method:   strings.ToUpper(method),
+		pattern:  strings.Split(strings.Trim(pattern, "/"), "/"),
+		source:   method + " " + pattern,
+		query:    make(map[string]string),
+		header:   make(http.Header),
+		maxCalls: -1,
+	}
+	s.mutex.Lock()
+	s.expectations = append(s.expectations, expectation)
+	s.mutex.Unlock()
+	return expectation
+}
+
+// AssertExpectations reports expectations that were not called the required
+// number of times and requests that matched no expectation.
+func (s *Server) AssertExpectations(t testing.TB) {
+	t.Helper()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, expectation := range s.expectations {
+		expectation.mutex.Lock()
+		calls, want := expectation.calls, expectation.maxCalls
+		expectation.mutex.Unlock()
+		switch {
+		case want < 0 && calls == 0:
+			t.Errorf("expectation %s was not called", expectation.source)
+		case want >= 0 && calls != want:
+			t.Errorf("expectation %s called %d times, want %d", expectation.source, calls, want)
+		}
+	}
+	for _, request := range s.unexpected {
+		t.Errorf("unexpected request %s", request)
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, defaultMaxBodyBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	s.mutex.Lock()
+	var matched *Expectation
+	var response Response
+	for _, expectation := range s.expectations {
+		if ok, reply := expectation.take(r, body); ok {
+			matched, response = expectation, reply
+			break
+		}
+	}
+	if matched == nil {
+		s.unexpected = append(s.unexpected, r.Method+" "+r.URL.RequestURI())
+	}
+	s.mutex.Unlock()
+
+	if matched == nil {
+		http.Error(w, "no expectation matches "+r.Method+" "+r.URL.RequestURI(), http.StatusNotImplemented)
+		return
+	}
+	response.write(w, r)
+}
+
+// Expectation describes requests the server accepts and the responses it
+// returns. Respond steps are used in order and the last one repeats.
+type Expectation struct {
+	mutex     sync.Mutex
+	method    string
+	pattern   []string
+	source    string
+	query     map[string]string
+	header    http.Header
+	jsonBody  any
+	hasJSON   bool
+	maxCalls  int
+	calls     int
+	responses []Response
+}
+
+func (e *Expectation) WithQuery(key, value string) *Expectation {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.query[key] = value
+	return e
+}
+
+func (e *Expectation) WithHeader(key, value string) *Expectation {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.header.Add(key, value)
+	return e
+}
+
+// WithJSONBody requires a request body that is semantically equal JSON.
+func (e *Expectation) WithJSONBody(value any) *Expectation {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(fmt.Sprintf("httpxtest: marshal expected body: %v", err))
+	}
+	var normalized any
+	_ = json.Unmarshal(data, &normalized)
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.jsonBody, e.hasJSON = normalized, true
+	return e
+}
+
+// Times requires exactly count calls. Later requests fall through to the
+// next matching expectation.
+func (e *Expectation) Times(count int) *Expectation {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.maxCalls = max(count, 0)
+	return e
+}
+
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+// Respond appends response steps. Without steps the server replies 200 with
+// an empty body.
+func (e *Expectation) Respond(responses ...Response) *Expectation {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.responses = append(e.responses, responses...)
+	return e
+}
+
+// RespondFunc appends a step served by handler.
+func (e *Expectation) RespondFunc(handler http.HandlerFunc) *Expectation {
+	return e.Respond(Response{Handler: handler})
+}
+
+// Calls returns how many requests matched the expectation.
+func (e *Expectation) Calls() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.calls
+}
+
+func (e *Expectation) take(r *http.Request, body []byte) (bool, Response) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.maxCalls >= 0 && e.calls >= e.maxCalls {
+		return false, Response{}
+	}
+	if e.method != "" && e.method != "*" && e.method != r.Method {
+		return false, Response{}
+	}
+	values, ok := matchPath(e.pattern, r.URL.Path)
+	if !ok {
+		return false, Response{}
+	}
+	query := r.URL.Query()
+	for key, value := range e.query {
+		if !query.Has(key) || query.Get(key) != value {
+			return false, Response{}
+		}
+	}
+	for key, wanted := range e.header {
+		actual := r.Header.Values(key)
+		for _, value := range wanted {
+			if !containsString(actual, value) {
+				return false, Response{}
+			}
+		}
+	}
+	if e.hasJSON {
+		var actual any
+		if err := json.Unmarshal(body, &actual); err != nil || !reflect.DeepEqual(actual, e.jsonBody) {
+			return false, Response{}
+		}
+	}
+
+	for name, value := range values {
+		r.SetPathValue(name, value)
+	}
+	response := Response{Status: http.StatusOK}
+	if len(e.responses) > 0 {
+		response = e.responses[min(e.calls, len(e.responses)-1)]
+	}
+	e.calls++
+	return true, response
+}
+
+func matchPath(pattern []string, path string) (map[string]string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	values := make(map[string]string)
+	for index, part := range pattern {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "...}") {
+			values[strings.TrimSuffix(part[1:], "...}")] = strings.Join(segments[min(index, len(segments)):], "/")
+			return values, true
+		}
+		if index >= len(segments) {
+			return nil, false
+		}
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if segments[index] == "" {
+				return nil, false
+			}
+			values[part[1:len(part)-1]] = segments[index]
+			continue
+		}
+		if part != segments[index] {
+			return nil, false
+		}
+	}
+	return values, len(pattern) == len(segments)
+}
+
+func containsString(values []string, wanted string) bool {
+	for _, value := range values {
+		if value == wanted {
+			return true
+		}
+	}
+	return false
+}
+
+// Response is one canned reply. Chunks are flushed one at a time with
+// ChunkInterval between them. Delay postpones the status line, Reset aborts
+// the connection without a response, and Handler replaces the whole reply.
+type Response struct {
+	Status        int
+	Header        http.Header
+	Body          []byte
+	Chunks        [][]byte
+	ChunkInterval time.Duration
+	Delay         time.Duration
+	Reset         bool
+	Handler       http.HandlerFunc
+}
+
+func Text(status int, body string) Response {
+	return Response{
+		Status: status,
+		Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:   []byte(body),
+	}
+}
+
+// JSON encodes value as the response body. It panics if value cannot be
+// encoded, which indicates a broken test.
+func JSON(status int, value any) Response {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(fmt.Sprintf("httpxtest: marshal response: %v", err))
+	}
+	return Response{
+		Status: status,
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   data,
+	}
+}
+
+// Chunked streams chunks with interval between flushes.
+func Chunked(status int, interval time.Duration, chunks ...string) Response {
+	response := Response{Status: status, ChunkInterval: interval}
+	for _, chunk := range chunks {
+		response.Chunks = append(response.Chunks, []byte(chunk))
+	}
+	return response
+}
+
+// Events streams text/event-stream events, one chunk per event.
+func Events(interval time.Duration, events ...httpx.Event) Response {
+	response := Response{
+		Status:        http.StatusOK,
+		Header:        http.Header{"Content-Type": {"text/event-stream"}},
+		ChunkInterval: interval,
+	}
+	for _, event := range events {
+		var builder strings.Builder
+		if event.ID != "" {
+			builder.WriteString("id: " + event.ID + "\n")
+		}
+		if event.Event != "" {
+			builder.WriteString("event: " + event.Event + "\n")
+		}
+		if event.Retry > 0 {
+			builder.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+		}
+		for _, line := range strings.Split(event.Data, "\n") {
+			builder.WriteString("data: " + line + "\n")
+		}
+		builder.WriteString("\n")
+		response.Chunks = append(response.Chunks, []byte(builder.String()))
+	}
+	return response
+}
+
+// ConnectionReset aborts the connection, which clients observe as a
+// transport error.
+func ConnectionReset() Response {
+	return Response{Reset: true}
+}
+
+func (r Response) WithHeader(key, value string) Response {
+	r.Header = r.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	r.Header.Add(key, value)
+	return r
+}
+
+func (r Response) WithDelay(delay time.Duration) Response {
+	r.Delay = delay
+	return r
+}
+
+func (r Response) write(w http.ResponseWriter, request *http.Request) {
+	if r.Delay > 0 {
+		timer := time.NewTimer(r.Delay)
+		select {
+		case <-request.Context().Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+	if r.Reset {
+		resetConnection(w)
+		return
+	}
+	if r.Handler != nil {
+		r.Handler(w, request)
+		return
+	}
+	for key, values := range r.Header {
+		w.Header()[key] = append([]string(nil), values...)
+	}
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(r.Body)
+	flusher, _ := w.(http.Flusher)
+	for index, chunk := range r.Chunks {
+		if index > 0 && r.ChunkInterval > 0 {
+			select {
+			case <-request.Context().Done():
+				return
+			case <-time.After(r.ChunkInterval):
+			}
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic("httpxtest: response writer cannot be hijacked")
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+}
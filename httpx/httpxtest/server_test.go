@@ -0,0 +1,126 @@
+package httpxtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iEvan-lhr/exciting-tool/httpx"
+)
+
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestServerRetriesThroughFailures(t *testing.T) {
+	server := NewServer(t)
+	items := server.Expect(http.MethodGet, "/items/{id}").WithQuery("view", "full").Times(4).Respond(
+		ConnectionReset(),
+		Text(http.StatusServiceUnavailable, "down"),
+		Text(http.StatusBadGateway, "down"),
+		Response{Handler: func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "item "+r.PathValue("id"))
+		}},
+	)
+	client := server.Client(httpx.WithRetry(httpx.RetryPolicy{
+		MaxAttempts:          4,
+		BaseDelay:            time.Microsecond,
+		RetryTransportErrors: true,
+	}))
+	response, err := client.Do(context.Background(), http.MethodGet, server.URL+"/items/42?view=full", nil, nil)
+	if err != nil || string(response.Body) != "item 42" {
+		t.Fatalf("response = %+v, error = %v", response, err)
+	}
+	if items.Calls() != 4 {
+		t.Fatalf("calls = %d", items.Calls())
+	}
+	server.AssertExpectations(t)
+}
+
+func TestServerMatchesJSONAndStreams(t *testing.T) {
+	server := NewServer(t)
+	server.Expect(http.MethodPost, "/orders").
+		WithHeader("Content-Type", "application/json").
+		WithJSONBody(map[string]any{"sku": "A-1", "count": 2}).
+		Once().
+		Respond(JSON(http.StatusCreated, map[string]string{"id": "o-1"}))
+	server.Expect(http.MethodGet, "/export/{path...}").Respond(
+		Chunked(http.StatusOK, time.Millisecond, "1234", "5678").WithDelay(time.Millisecond),
+	)
+	server.Expect(http.MethodGet, "/events").Respond(
+		Events(time.Millisecond, httpx.Event{ID: "1", Data: "a\nb"}, httpx.Event{ID: "2", Event: "done", Data: "c"}),
+	)
+	client := server.Client()
+
+	var created struct{ ID string }
+	if _, err := client.PostJSON(context.Background(), server.URL+"/orders", map[string]any{"count": 2, "sku": "A-1"}, &created); err != nil || created.ID != "o-1" {
+		t.Fatalf("created = %+v, error = %v", created, err)
+	}
+
+	stream, err := client.DoStream(context.Background(), http.MethodGet, server.URL+"/export/a/b.csv", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CheckStatus(0); err != nil {
+		t.Fatal(err)
+	}
+	stream.LimitBody(6)
+	data, err := io.ReadAll(stream.Body)
+	_ = stream.Close()
+	if !errors.Is(err, httpx.ErrBodyTooLarge) || string(data) != "123456" {
+		t.Fatalf("limited body = %q, error = %v", data, err)
+	}
+
+	events, err := client.OpenEventStream(context.Background(), httpx.Request{URL: server.URL + "/events"}, httpx.EventStreamOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+	var received []string
+	for {
+		event, err := events.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, event.ID+":"+event.Event+":"+event.Data)
+	}
+	if strings.Join(received, "|") != "1:message:a\nb|2:done:c" {
+		t.Fatalf("events = %q", received)
+	}
+	server.AssertExpectations(t)
+}
+
+func TestServerReportsUnmetExpectations(t *testing.T) {
+	server := NewServer(t)
+	server.Expect(http.MethodGet, "/never")
+	server.Expect(http.MethodDelete, "/twice").Times(2)
+	client := server.Client()
+	if _, err := client.Do(context.Background(), http.MethodDelete, server.URL+"/twice", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	response, err := client.Do(context.Background(), http.MethodGet, server.URL+"/other", nil, nil)
+	if err != nil || response.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("unexpected response = %+v, error = %v", response, err)
+	}
+
+	recorder := &recordingTB{TB: t}
+	server.AssertExpectations(recorder)
+	if len(recorder.errors) != 3 {
+		t.Fatalf("errors = %q", recorder.errors)
+	}
+}
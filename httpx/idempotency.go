@@ -0,0 +1,84 @@
+package httpx
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultIdempotencyHeader = "Idempotency-Key"
+
+// IdempotencyPolicy configures idempotency keys. Header defaults to
+// Idempotency-Key and Methods to POST and PATCH. NewKey generates keys; the
+// default is a random UUID.
+type IdempotencyPolicy struct {
+	Header  string
+	Methods []string
+	NewKey  func() (string, error)
+}
+
+// WithIdempotencyKeys attaches an idempotency key to requests whose method is
+// in policy.Methods. The key is generated once per DoRequest call and reused
+// by every attempt of that call; a request that already carries the header
+// keeps its value, so callers can reuse a key across calls. Requests with a
+// key may be retried under the client's RetryPolicy even when their method is
+// not in RetryPolicy.Methods.
+func WithIdempotencyKeys(policy IdempotencyPolicy) Option {
+	return func(client *Client) {
+		if policy.Header == "" {
+			policy.Header = defaultIdempotencyHeader
+		}
+		if len(policy.Methods) == 0 {
+			policy.Methods = []string{http.MethodPost, http.MethodPatch}
+		} else {
+			policy.Methods = append([]string(nil), policy.Methods...)
+		}
+		if policy.NewKey == nil {
+			policy.NewKey = newUUID
+		}
+		client.idempotency = &policy
+	}
+}
+
+// idempotencyKey returns request with a key attached when the policy covers
+// its method. The boolean reports whether the request carries a key.
+func (c *Client) idempotencyKey(request Request) (Request, bool, error) {
+	policy := c.idempotency
+	if policy == nil || !policy.allowsMethod(request.Method) {
+		return request, false, nil
+	}
+	if request.Header.Get(policy.Header) != "" {
+		return request, true, nil
+	}
+	key, err := policy.NewKey()
+	if err != nil {
+		return request, false, fmt.Errorf("generate idempotency key: %w", err)
+	}
+	request.Header = request.Header.Clone()
+	if request.Header == nil {
+		request.Header = make(http.Header)
+	}
+	request.Header.Set(policy.Header, key)
+	return request, true, nil
+}
+
+func (p *IdempotencyPolicy) allowsMethod(method string) bool {
+	for _, allowed := range p.Methods {
+		if strings.EqualFold(method, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() (string, error) {
+	var value [16]byte
+	if _, err := rand.Read(value[:]); err != nil {
+		return "", err
+	}
+	value[6] = value[6]&0x0f | 0x40
+	value[8] = value[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", value[0:4], value[4:6], value[6:8], value[8:10], value[10:16]), nil
+}
@@ -0,0 +1,93 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeyEnablesPostRetries(t *testing.T) {
+	var mutex sync.Mutex
+	var keys, bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		bodies = append(bodies, string(body))
+		first := len(keys)%2 == 1
+		mutex.Unlock()
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := New(
+		WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}),
+		WithIdempotencyKeys(IdempotencyPolicy{}),
+	)
+	for range 2 {
+		response, err := client.Do(context.Background(), http.MethodPost, server.URL, bytes.NewReader([]byte("pay")), nil)
+		if err != nil || response.StatusCode != http.StatusCreated {
+			t.Fatalf("response = %+v, err = %v", response, err)
+		}
+	}
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if len(keys) != 4 || keys[0] != keys[1] || keys[2] != keys[3] || keys[0] == keys[2] || !uuid.MatchString(keys[0]) {
+		t.Fatalf("keys = %q", keys)
+	}
+	for _, body := range bodies {
+		if body != "pay" {
+			t.Fatalf("bodies = %q", bodies)
+		}
+	}
+
+	keys = nil
+	header := http.Header{"Idempotency-Key": {"order-42"}}
+	if _, err := client.Do(context.Background(), http.MethodPatch, server.URL, nil, header); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "order-42" || keys[1] != "order-42" {
+		t.Fatalf("caller keys = %q", keys)
+	}
+}
+
+func TestPostWithoutIdempotencyKeyIsNotRetried(t *testing.T) {
+	var mutex sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		mutex.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := New(
+		WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}),
+		WithIdempotencyKeys(IdempotencyPolicy{Methods: []string{http.MethodPatch}}),
+	)
+	response, err := client.Do(context.Background(), http.MethodPost, server.URL, nil, nil)
+	if err != nil || response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("response = %+v, err = %v", response, err)
+	}
+	if len(keys) != 1 || keys[0] != "" {
+		t.Fatalf("keys = %q", keys)
+	}
+
+	failing := New(WithIdempotencyKeys(IdempotencyPolicy{NewKey: func() (string, error) {
+		return "", errors.New("no entropy")
+	}}))
+	if _, err := failing.Do(context.Background(), http.MethodPost, server.URL, nil, nil); err == nil {
+		t.Fatal("expected key generation error")
+	}
+}
@@ -0,0 +1,132 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestMutator modifies a prepared request before it is sent. It runs once
+// per attempt, after default and per-request headers have been applied.
+type RequestMutator func(*http.Request) error
+
+// Sender sends one prepared request attempt.
+type Sender func(*http.Request) (*http.Response, error)
+
+// Interceptor wraps one request attempt. It may modify the request, inspect or
+// replace the response, or return a result without calling next.
+// An interceptor that replaces a response must close the body it discards.
+//
+// Each attempt made by DoRequest runs its hooks in this order:
+//
+//  1. client default headers, then Request.Header
+//  2. request mutators, in the order they were added
+//  3. request validators
+//  4. interceptors, the first added being the outermost
+//  5. signers, in the order they were added
+//  6. request validators again, immediately before the transport, when the
+//     client has interceptors or signers that may have changed the request
+//  7. attempt events for Observers, then response observers, each in the
+//     order they were added
+//
+// Validators run before the interceptors so that an interceptor returning a
+// result without calling next cannot skip them, and again after the signers so
+// they see the request exactly as it will be sent.
+// The sequence repeats for every retry, so hooks see fresh requests.
+type Interceptor func(request *http.Request, next Sender) (*http.Response, error)
+
+// ResponseObserver sees the outcome of every attempt, including attempts that
+// will be retried. It must not read or close the response body.
+type ResponseObserver func(*http.Request, *http.Response, error)
+
+type attemptContextKey struct{}
+
+// WithRequestMutator adds a mutator that runs before every request attempt.
+func WithRequestMutator(mutator RequestMutator) Option {
+	return func(client *Client) {
+		if mutator != nil {
+			client.requestMutators = append(client.requestMutators, mutator)
+		}
+	}
+}
+
+// WithInterceptor adds an interceptor around every request attempt.
+func WithInterceptor(interceptor Interceptor) Option {
+	return func(client *Client) {
+		if interceptor != nil {
+			client.interceptors = append(client.interceptors, interceptor)
+		}
+	}
+}
+
+// WithResponseObserver adds an observer that runs after every request attempt.
+func WithResponseObserver(observer ResponseObserver) Option {
+	return func(client *Client) {
+		if observer != nil {
+			client.responseObservers = append(client.responseObservers, observer)
+		}
+	}
+}
+
+// AttemptFromContext returns the one-based attempt number of the request that
+// owns ctx. It reports false outside DoRequest.
+func AttemptFromContext(ctx context.Context) (int, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	attempt, ok := ctx.Value(attemptContextKey{}).(int)
+	return attempt, ok
+}
+
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptContextKey{}, attempt)
+}
+
+func (c *Client) intercept(request *http.Request) (*http.Response, error) {
+	next := c.send
+	for index := len(c.interceptors) - 1; index >= 0; index-- {
+		interceptor, inner := c.interceptors[index], next
+		next = func(request *http.Request) (*http.Response, error) {
+			return interceptor(request, inner)
+		}
+	}
+	return next(request)
+}
+
+func (c *Client) send(request *http.Request) (*http.Response, error) {
+	if len(c.signers) > 0 {
+		body, _ := request.Context().Value(bodyFactoryContextKey{}).(BodyFactory)
+		for _, signer := range c.signers {
+			if err := signer.Sign(request, body); err != nil {
+				if request.Body != nil {
+					_ = request.Body.Close()
+				}
+				return nil, err
+			}
+		}
+	}
+	if len(c.interceptors) > 0 || len(c.signers) > 0 {
+		if err := c.validate(request); err != nil {
+			return nil, err
+		}
+	}
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return httpClient.Do(request)
+}
+
+// validate runs the request validators, closing the body of a rejected
+// request.
+func (c *Client) validate(request *http.Request) error {
+	for _, validator := range c.requestValidators {
+		if err := validator(request); err != nil {
+			if request.Body != nil {
+				_ = request.Body.Close()
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInterceptorOrderingAcrossRetries(t *testing.T) {
+	var mutex sync.Mutex
+	var calls []string
+	record := func(value string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls = append(calls, value)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record("server:" + r.Header.Get("X-Trace"))
+		if r.Header.Get("X-Attempt") == "1" {
+			http.Error(w, "retry", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := New(
+		WithHeader("X-Trace", "default"),
+		WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Microsecond}),
+		WithRequestValidator(func(request *http.Request) error {
+			record("validator:" + request.Header.Get("X-Trace"))
+			return nil
+		}),
+		WithRequestMutator(func(request *http.Request) error {
+			attempt, _ := AttemptFromContext(request.Context())
+			request.Header.Set("X-Attempt", strconv.Itoa(attempt))
+			request.Header.Set("X-Trace", request.Header.Get("X-Trace")+">mutator")
+			return nil
+		}),
+		WithInterceptor(func(request *http.Request, next Sender) (*http.Response, error) {
+			request.Header.Set("X-Trace", request.Header.Get("X-Trace")+">outer")
+			response, err := next(request)
+			record("outer-after")
+			return response, err
+		}),
+		WithInterceptor(func(request *http.Request, next Sender) (*http.Response, error) {
+			request.Header.Set("X-Trace", request.Header.Get("X-Trace")+">inner")
+			return next(request)
+		}),
+		WithResponseObserver(func(request *http.Request, response *http.Response, err error) {
+			record("observer:" + request.Header.Get("X-Attempt") + ":" + strconv.Itoa(response.StatusCode))
+		}),
+	)
+	response, err := client.DoRequest(context.Background(), Request{
+		URL:    server.URL,
+		Header: http.Header{"X-Trace": {"request"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Close()
+
+	trace := "request>mutator>outer>inner"
+	want := []string{
+		"validator:request>mutator", "validator:" + trace, "server:" + trace, "outer-after", "observer:1:503",
+		"validator:request>mutator", "validator:" + trace, "server:" + trace, "outer-after", "observer:2:200",
+	}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("calls = %q", calls)
+	}
+}
+
+func TestInterceptorShortCircuitAndMutatorError(t *testing.T) {
+	client := New(WithInterceptor(func(request *http.Request, _ Sender) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusTeapot,
+			Header:     http.Header{"X-Stub": {"yes"}},
+			Body:       io.NopCloser(strings.NewReader("stub")),
+			Request:    request,
+		}, nil
+	}))
+	response, err := client.Do(context.Background(), http.MethodGet, "http://example.test", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusTeapot || string(response.Body) != "stub" {
+		t.Fatalf("response = %+v", response)
+	}
+
+	client = New(
+		WithRequestValidator(AllowHosts("allowed.test")),
+		WithInterceptor(func(*http.Request, Sender) (*http.Response, error) {
+			t.Fatal("interceptor ran for a request the validators reject")
+			return nil, nil
+		}),
+	)
+	if _, err := client.Do(context.Background(), http.MethodGet, "http://example.test", nil, nil); err == nil {
+		t.Fatal("short-circuiting interceptor bypassed the host allowlist")
+	}
+
+	denied := errors.New("denied")
+	observer := &recordingObserver{}
+	var calls atomic.Int32
+	client = New(
+		WithObserver(observer),
+		WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Microsecond, RetryTransportErrors: true}),
+		WithRequestMutator(func(*http.Request) error {
+			if calls.Add(1) == 1 {
+				return denied
+			}
+			return nil
+		}),
+		WithInterceptor(func(request *http.Request, _ Sender) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: request}, nil
+		}),
+	)
+	if _, err := client.Do(context.Background(), http.MethodGet, "http://example.test", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(observer.attempts) != 2 || observer.attempts[0].Attempt != 1 ||
+		!errors.Is(observer.attempts[0].Err, denied) || observer.attempts[1].Attempt != 2 {
+		t.Fatalf("attempts = %+v", observer.attempts)
+	}
+}
+
+func TestValidatorsRunOnceWithoutInterceptors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var calls atomic.Int32
+	client := New(WithRequestValidator(func(*http.Request) error {
+		calls.Add(1)
+		return nil
+	}))
+	if _, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("validator calls = %d", calls.Load())
+	}
+}
@@ -0,0 +1,249 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxPartBytes      int64 = 32 << 20
+	defaultMaxMultipartBytes int64 = 256 << 20
+	defaultMaxParts                = 1000
+	maxFileNameBytes               = 255
+)
+
+var (
+	ErrNotMultipart = errors.New("response is not a multipart body")
+	ErrPartTooLarge = errors.New("multipart part exceeds configured limit")
+	ErrTooManyParts = errors.New("multipart body exceeds the part limit")
+)
+
+// MultipartReaderOptions bounds multipart response parsing. MaxPartBytes
+// limits the body of each part and MaxTotalBytes the whole response body,
+// including part headers and boundaries; exceeding them fails with
+// ErrPartTooLarge and ErrBodyTooLarge. Zero values use 32 MiB, 256 MiB, and
+// 1000 parts.
+type MultipartReaderOptions struct {
+	MaxPartBytes  int64
+	MaxTotalBytes int64
+	MaxParts      int
+}
+
+// MultipartReader reads the parts of a multipart/mixed, multipart/form-data,
+// or other multipart response as they arrive. It is intended for one consumer.
+type MultipartReader struct {
+	response *StreamResponse
+	reader   *multipart.Reader
+	options  MultipartReaderOptions
+	parts    int
+	current  *Part
+}
+
+// NewMultipartReader reads response as a multipart body. It fails with
+// ErrNotMultipart when the Content-Type is not multipart or has no boundary.
+// Check the status before calling it. Closing the reader closes the response.
+func NewMultipartReader(response *StreamResponse, options MultipartReaderOptions) (*MultipartReader, error) {
+	if response == nil || response.Body == nil {
+		return nil, errors.New("cannot read a nil response")
+	}
+	mediaType, params, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil, fmt.Errorf("%w: %q", ErrNotMultipart, response.Header.Get("Content-Type"))
+	}
+	if options.MaxPartBytes <= 0 {
+		options.MaxPartBytes = defaultMaxPartBytes
+	}
+	if options.MaxTotalBytes <= 0 {
+		options.MaxTotalBytes = defaultMaxMultipartBytes
+	}
+	if options.MaxParts <= 0 {
+		options.MaxParts = defaultMaxParts
+	}
+	response.LimitBody(options.MaxTotalBytes)
+	return &MultipartReader{
+		response: response,
+		reader:   multipart.NewReader(response.Body, params["boundary"]),
+		options:  options,
+	}, nil
+}
+
+// Next returns the next part, discarding what is left of the previous one. It
+// returns io.EOF after the last part.
+func (r *MultipartReader) Next() (*Part, error) {
+	if r.current != nil {
+		_, _ = io.Copy(io.Discard, r.current.part)
+		_ = r.current.part.Close()
+		r.current = nil
+	}
+	part, err := r.reader.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	r.parts++
+	if r.parts > r.options.MaxParts {
+		_ = part.Close()
+		return nil, fmt.Errorf("%w: %d parts", ErrTooManyParts, r.options.MaxParts)
+	}
+	r.current = &Part{
+		Header:   part.Header,
+		FormName: part.FormName(),
+		FileName: part.FileName(),
+		part:     part,
+		body: &limitedReadCloser{
+			body:      part,
+			remaining: r.options.MaxPartBytes,
+			tooLarge:  ErrPartTooLarge,
+		},
+	}
+	return r.current, nil
+}
+
+// Parts iterates the remaining parts and closes the reader when iteration
+// stops. Each part is valid only until the next iteration. An error is
+// yielded once and ends the iteration.
+func (r *MultipartReader) Parts() iter.Seq2[*Part, error] {
+	return func(yield func(*Part, error) bool) {
+		defer r.Close()
+		for {
+			part, err := r.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(part, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// SaveFiles saves the remaining file parts, those with a filename, into dir
+// and discards the other parts. Each file is named with SanitizeFileName,
+// created with mode 0600, and streamed to disk. Files are never replaced: a
+// name that already exists in dir fails with an error that wraps
+// fs.ErrExist. Files saved before an error are left in place and returned.
+func (r *MultipartReader) SaveFiles(dir string) ([]SavedPart, error) {
+	var saved []SavedPart
+	for part, err := range r.Parts() {
+		if err != nil {
+			return saved, err
+		}
+		if part.FileName == "" {
+			continue
+		}
+		path := filepath.Join(dir, SanitizeFileName(part.FileName))
+		size, err := part.save(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+		if err != nil {
+			return saved, err
+		}
+		saved = append(saved, SavedPart{
+			Header:   part.Header,
+			FormName: part.FormName,
+			FileName: part.FileName,
+			Path:     path,
+			Size:     size,
+		})
+	}
+	return saved, nil
+}
+
+// Close closes the response.
+func (r *MultipartReader) Close() error {
+	r.current = nil
+	return r.response.Close()
+}
+
+// SavedPart describes a part written to disk by SaveFiles.
+type SavedPart struct {
+	Header   textproto.MIMEHeader
+	FormName string
+	FileName string
+	Path     string
+	Size     int64
+}
+
+// Part is one part of a multipart response. FormName is the name parameter
+// of a form-data Content-Disposition, and FileName the base name of its
+// filename parameter, if any. Read returns ErrPartTooLarge once the part
+// exceeds MaxPartBytes.
+type Part struct {
+	Header   textproto.MIMEHeader
+	FormName string
+	FileName string
+
+	part *multipart.Part
+	body *limitedReadCloser
+}
+
+func (p *Part) ContentType() string {
+	return p.Header.Get("Content-Type")
+}
+
+func (p *Part) Read(target []byte) (int, error) {
+	return p.body.Read(target)
+}
+
+// SaveAs streams the rest of the part to path, creating it with mode 0600 or
+// truncating it. The file is removed if the part cannot be read completely.
+func (p *Part) SaveAs(path string) (int64, error) {
+	return p.save(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+}
+
+func (p *Part) save(path string, flag int) (int64, error) {
+	file, err := os.OpenFile(path, flag, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(file, p)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return size, err
+	}
+	return size, nil
+}
+
+// SanitizeFileName reduces a client-supplied filename to a safe base name. It
+// drops any directory part, whether separated by slashes or backslashes,
+// removes control characters and leading dots, and limits the name to 255
+// bytes, keeping the extension. Names with nothing left become "file".
+func SanitizeFileName(name string) string {
+	if index := strings.LastIndexAny(name, `/\`); index >= 0 {
+		name = name[index+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) || strings.ContainsRune(`<>:"|?*`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	name = strings.TrimRight(name, ". ")
+	if len(name) > maxFileNameBytes {
+		extension := filepath.Ext(name)
+		if len(extension) > maxFileNameBytes/2 {
+			extension = ""
+		}
+		base := name[:len(name)-len(extension)]
+		cut := maxFileNameBytes - len(extension)
+		for cut > 0 && !utf8.RuneStart(base[cut]) {
+			cut--
+		}
+		name = base[:cut] + extension
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
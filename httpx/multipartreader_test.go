@@ -0,0 +1,157 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func multipartServer(t *testing.T, write func(*multipart.Writer)) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		write(writer)
+		_ = writer.Close()
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+		_, _ = w.Write(body.Bytes())
+	}))
+}
+
+func writePart(writer *multipart.Writer, disposition, contentType, body string) {
+	header := textproto.MIMEHeader{"Content-Type": {contentType}}
+	if disposition != "" {
+		header.Set("Content-Disposition", disposition)
+	}
+	part, _ := writer.CreatePart(header)
+	_, _ = io.WriteString(part, body)
+}
+
+func TestMultipartReaderIteratesAndSavesFiles(t *testing.T) {
+	server := multipartServer(t, func(writer *multipart.Writer) {
+		writePart(writer, "", "application/json", `{"batch":1}`)
+		writePart(writer, `form-data; name="doc"; filename="../report.txt"`, "text/plain", "report body")
+		writePart(writer, `attachment; filename="C:\\Users\\ann\\data.bin"`, "application/octet-stream", strings.Repeat("d", 1000))
+	})
+	defer server.Close()
+
+	client := New()
+	response, err := client.DoStream(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := NewMultipartReader(response, MultipartReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(first)
+	if first.ContentType() != "application/json" || string(data) != `{"batch":1}` || first.FileName != "" {
+		t.Fatalf("first part = %+v, %q", first, data)
+	}
+
+	dir := t.TempDir()
+	saved, err := reader.SaveFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 || saved[0].FormName != "doc" || saved[0].Path != filepath.Join(dir, "report.txt") ||
+		saved[0].Size != 11 || saved[1].Size != 1000 || saved[1].Path != filepath.Join(dir, "data.bin") {
+		t.Fatalf("saved = %+v", saved)
+	}
+	if info, err := os.Stat(saved[1].Path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("saved file info = %v, %v", info, err)
+	}
+	if content, _ := os.ReadFile(saved[0].Path); string(content) != "report body" {
+		t.Fatalf("saved content = %q", content)
+	}
+
+	response, err = client.DoStream(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, _ = NewMultipartReader(response, MultipartReaderOptions{})
+	if _, err := reader.SaveFiles(dir); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("existing file err = %v", err)
+	}
+}
+
+func TestMultipartReaderLimits(t *testing.T) {
+	server := multipartServer(t, func(writer *multipart.Writer) {
+		writePart(writer, `attachment; filename="a.txt"`, "text/plain", strings.Repeat("a", 100))
+		writePart(writer, `attachment; filename="b.txt"`, "text/plain", strings.Repeat("b", 100))
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		options MultipartReaderOptions
+		want    error
+	}{
+		{name: "part", options: MultipartReaderOptions{MaxPartBytes: 50}, want: ErrPartTooLarge},
+		{name: "total", options: MultipartReaderOptions{MaxTotalBytes: 200}, want: ErrBodyTooLarge},
+		{name: "count", options: MultipartReaderOptions{MaxParts: 1}, want: ErrTooManyParts},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := New().DoStream(context.Background(), http.MethodGet, server.URL, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader, err := NewMultipartReader(response, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var failure error
+			for part, err := range reader.Parts() {
+				if err != nil {
+					failure = err
+					break
+				}
+				if _, err := io.Copy(io.Discard, part); err != nil {
+					failure = err
+					break
+				}
+			}
+			if !errors.Is(failure, test.want) {
+				t.Fatalf("err = %v, want %v", failure, test.want)
+			}
+		})
+	}
+
+	response := &StreamResponse{Header: http.Header{"Content-Type": {"application/json"}}, Body: http.NoBody}
+	if _, err := NewMultipartReader(response, MultipartReaderOptions{}); !errors.Is(err, ErrNotMultipart) {
+		t.Fatalf("non-multipart err = %v", err)
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := map[string]string{
+		"report.pdf":                      "report.pdf",
+		"../../etc/passwd":                "passwd",
+		`C:\Users\me\photo.jpg`:           "photo.jpg",
+		".hidden":                         "hidden",
+		"a\x00b\nc.txt":                   "abc.txt",
+		"..":                              "file",
+		"":                                "file",
+		`what?<is>"this".txt`:             "whatisthis.txt",
+		strings.Repeat("é", 200) + ".txt": strings.Repeat("é", 125) + ".txt",
+	}
+	for input, want := range tests {
+		if got := SanitizeFileName(input); got != want {
+			t.Errorf("SanitizeFileName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
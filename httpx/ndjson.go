@@ -0,0 +1,113 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+const (
+	NDJSONContentType           = "application/x-ndjson"
+	defaultMaxNDJSONRecordBytes = 1 << 20
+)
+
+var ErrRecordTooLarge = errors.New("ndjson record exceeds configured limit")
+
+// DecodeNDJSON decodes one JSON value per line. Blank lines are skipped and a
+// record larger than maxRecordBytes stops iteration with ErrRecordTooLarge.
+// A zero limit uses 1 MiB. The sequence reads from source and can be ranged
+// over only once.
+func DecodeNDJSON[T any](source io.Reader, maxRecordBytes int) iter.Seq2[T, error] {
+	if maxRecordBytes <= 0 {
+		maxRecordBytes = defaultMaxNDJSONRecordBytes
+	}
+	return func(yield func(T, error) bool) {
+		var zero T
+		if source == nil {
+			yield(zero, errors.New("ndjson source cannot be nil"))
+			return
+		}
+		reader := bufio.NewReader(source)
+		var line []byte
+		for number := 1; ; number++ {
+			var err error
+			line, err = readNDJSONLine(reader, line[:0], maxRecordBytes)
+			if err != nil && !errors.Is(err, io.EOF) {
+				yield(zero, fmt.Errorf("ndjson line %d: %w", number, err))
+				return
+			}
+			if record := bytes.TrimSpace(line); len(record) > 0 {
+				var value T
+				if decodeErr := json.Unmarshal(record, &value); decodeErr != nil {
+					yield(zero, fmt.Errorf("ndjson line %d: %w", number, decodeErr))
+					return
+				}
+				if !yield(value, nil) {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// StreamNDJSON decodes response records like DecodeNDJSON and closes the
+// response body when iteration stops. Check the status before ranging.
+func StreamNDJSON[T any](response *StreamResponse, maxRecordBytes int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if response == nil || response.Body == nil {
+			var zero T
+			yield(zero, errors.New("cannot decode a nil response"))
+			return
+		}
+		defer response.Close()
+		for value, err := range DecodeNDJSON[T](response.Body, maxRecordBytes) {
+			if !yield(value, err) {
+				return
+			}
+		}
+	}
+}
+
+// NDJSONBody returns a BodyFactory that encodes records as newline-delimited
+// JSON. Every call ranges over records again, so the body is replayable when
+// records is, as sequences over slices and maps are.
+func NDJSONBody[T any](records iter.Seq[T]) BodyFactory {
+	return func() (io.ReadCloser, error) {
+		if records == nil {
+			return nil, errors.New("ndjson records cannot be nil")
+		}
+		reader, writer := io.Pipe()
+		go func() {
+			encoder := json.NewEncoder(writer)
+			var err error
+			for record := range records {
+				if err = encoder.Encode(record); err != nil {
+					break
+				}
+			}
+			_ = writer.CloseWithError(err)
+		}()
+		return reader, nil
+	}
+}
+
+func readNDJSONLine(reader *bufio.Reader, line []byte, limit int) ([]byte, error) {
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		content := bytes.TrimRight(chunk, "\r\n")
+		if len(line)+len(content) > limit {
+			return line, ErrRecordTooLarge
+		}
+		line = append(line, chunk...)
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return line, err
+		}
+	}
+}
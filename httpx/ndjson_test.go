@@ -0,0 +1,99 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type ndjsonRecord struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDecodeNDJSON(t *testing.T) {
+	input := "{\"id\":1,\"name\":\"a\"}\r\n\n  \n{\"id\":2,\"name\":\"b\"}"
+	var records []ndjsonRecord
+	for record, err := range DecodeNDJSON[ndjsonRecord](strings.NewReader(input), 0) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 || records[0].Name != "a" || records[1].ID != 2 {
+		t.Fatalf("records = %+v", records)
+	}
+}
+
+func TestDecodeNDJSONErrors(t *testing.T) {
+	var lastErr error
+	for _, err := range DecodeNDJSON[ndjsonRecord](strings.NewReader("{\"id\":1}\n{\"name\":\"0123456789\"}\n"), 16) {
+		lastErr = err
+	}
+	if !errors.Is(lastErr, ErrRecordTooLarge) {
+		t.Fatalf("size error = %v", lastErr)
+	}
+	for _, err := range DecodeNDJSON[ndjsonRecord](strings.NewReader("{\"id\":1}\nnot-json\n"), 0) {
+		lastErr = err
+	}
+	if lastErr == nil || !strings.Contains(lastErr.Error(), "line 2") {
+		t.Fatalf("decode error = %v", lastErr)
+	}
+}
+
+func TestNDJSONRoundTripWithRetry(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read request: %v", err)
+		}
+		if string(data) != "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"b\"}\n" {
+			t.Errorf("request body = %q", data)
+		}
+		if attempts.Add(1) == 1 {
+			http.Error(w, "retry", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", NDJSONContentType)
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	client := New(WithRetry(RetryPolicy{
+		MaxAttempts: 2,
+		BaseDelay:   time.Microsecond,
+		Methods:     []string{http.MethodPost},
+	}))
+	sent := []ndjsonRecord{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+	response, err := client.DoRequest(context.Background(), Request{
+		Method:        http.MethodPost,
+		URL:           server.URL,
+		Header:        http.Header{"Content-Type": {NDJSONContentType}},
+		Body:          NDJSONBody(slices.Values(sent)),
+		ContentLength: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := response.CheckStatus(0); err != nil {
+		t.Fatal(err)
+	}
+	var received []ndjsonRecord
+	for record, err := range StreamNDJSON[ndjsonRecord](response, 0) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, record)
+	}
+	if !slices.Equal(received, sent) || attempts.Load() != 2 {
+		t.Fatalf("received = %+v, attempts = %d", received, attempts.Load())
+	}
+}
@@ -0,0 +1,373 @@
+package httpx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTokenEarlyRefresh = 30 * time.Second
+
+var ErrNoAccessToken = errors.New("token response has no access token")
+
+// Token is an OAuth2 access token. A zero Expiry means the token does not
+// expire.
+type Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+// TokenSource supplies access tokens. Implementations must be safe for
+// concurrent use.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenError is an error response from a token endpoint.
+type TokenError struct {
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+func (e *TokenError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth2 token request failed with status %d: %s: %s", e.StatusCode, e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth2 token request failed with status %d: %s", e.StatusCode, e.Code)
+}
+
+func (t *Token) authorization() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+// ClientCredentials fetches tokens with the client credentials grant. Client
+// sends the token request and defaults to New().
+type ClientCredentials struct {
+	TokenURL       string
+	ClientID       string
+	ClientSecret   string
+	Scopes         []string
+	EndpointParams url.Values
+	Client         *Client
+}
+
+func (c *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	for key, values := range c.EndpointParams {
+		form[key] = append([]string(nil), values...)
+	}
+	return requestToken(ctx, c.Client, c.TokenURL, c.ClientID, c.ClientSecret, form)
+}
+
+// RefreshTokenSource exchanges a refresh token for access tokens. A rotated
+// refresh token returned by the server replaces the current one.
+type RefreshTokenSource struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Client       *Client
+
+	mutex        sync.Mutex
+	refreshToken string
+}
+
+func NewRefreshTokenSource(tokenURL, clientID, clientSecret, refreshToken string) *RefreshTokenSource {
+	return &RefreshTokenSource{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		refreshToken: refreshToken,
+	}
+}
+
+func (s *RefreshTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.refreshToken == "" {
+		return nil, errors.New("refresh token cannot be empty")
+	}
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {s.refreshToken},
+	}
+	if len(s.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.Scopes, " "))
+	}
+	token, err := requestToken(ctx, s.Client, s.TokenURL, s.ClientID, s.ClientSecret, form)
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = s.refreshToken
+	}
+	s.refreshToken = token.RefreshToken
+	return token, nil
+}
+
+// CachingTokenSource caches tokens from another source. A token is refreshed
+// in the background once it is within the early refresh window of expiring,
+// and callers wait only when no valid token is available. The window is
+// capped at half the token's lifetime so that short-lived tokens are still
+// reused. Concurrent callers share a single refresh.
+type CachingTokenSource struct {
+	source       TokenSource
+	earlyRefresh time.Duration
+	now          func() time.Time
+
+	mutex      sync.Mutex
+	token      *Token
+	requested  time.Time
+	refreshing chan struct{}
+	refreshErr error
+}
+
+// ReuseTokenSource wraps source with a cache. A non-positive earlyRefresh
+// uses 30 seconds.
+func ReuseTokenSource(source TokenSource, earlyRefresh time.Duration) *CachingTokenSource {
+	if earlyRefresh <= 0 {
+		earlyRefresh = defaultTokenEarlyRefresh
+	}
+	return &CachingTokenSource{
+		source:       source,
+		earlyRefresh: earlyRefresh,
+		now:          time.Now,
+	}
+}
+
+func (s *CachingTokenSource) Token(ctx context.Context) (*Token, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for {
+		s.mutex.Lock()
+		now := s.now()
+		token := s.token
+		if token != nil && (token.Expiry.IsZero() || now.Add(s.refreshMargin()).Before(token.Expiry)) {
+			s.mutex.Unlock()
+			return token, nil
+		}
+		done := s.startRefresh(ctx)
+		if token != nil && now.Before(token.Expiry) {
+			s.mutex.Unlock()
+			return token, nil
+		}
+		s.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-done:
+		}
+		s.mutex.Lock()
+		token, err := s.token, s.refreshErr
+		s.mutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		// A token that is already expired, because of clock skew or an
+		// expires_in of zero, is returned rather than refreshed again, so
+		// that the token endpoint is called at most once per call.
+		if token != nil {
+			return token, nil
+		}
+	}
+}
+
+// Invalidate discards the cached token if its access token equals
+// accessToken, forcing the next call to refresh.
+func (s *CachingTokenSource) Invalidate(accessToken string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.token != nil && s.token.AccessToken == accessToken {
+		s.token = nil
+	}
+}
+
+// refreshMargin returns how long before expiry the cached token is
+// refreshed: the early refresh window, but at most half the token's lifetime.
+// It must be called with the mutex held.
+func (s *CachingTokenSource) refreshMargin() time.Duration {
+	if s.token == nil || s.requested.IsZero() {
+		return s.earlyRefresh
+	}
+	return min(s.earlyRefresh, s.token.Expiry.Sub(s.requested)/2)
+}
+
+// startRefresh must be called with the mutex held. It returns a channel that
+// is closed when the running or newly started refresh completes.
+func (s *CachingTokenSource) startRefresh(ctx context.Context) chan struct{} {
+	if s.refreshing != nil {
+		return s.refreshing
+	}
+	done := make(chan struct{})
+	s.refreshing = done
+	ctx = context.WithoutCancel(ctx)
+	// The lifetime is measured from the request, which errs on the short side.
+	requested := s.now()
+	go func() {
+		token, err := s.source.Token(ctx)
+		if err == nil && (token == nil || token.AccessToken == "") {
+			err = ErrNoAccessToken
+		}
+		s.mutex.Lock()
+		s.refreshErr = err
+		if err == nil {
+			s.token, s.requested = token, requested
+		}
+		s.refreshing = nil
+		s.mutex.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// WithTokenSource authorizes every attempt with a token from source. Sources
+// other than *CachingTokenSource are wrapped with ReuseTokenSource. When a
+// response is 401 Unauthorized, the token is invalidated and the attempt is
+// sent once more with a fresh token if the request body can be reopened.
+func WithTokenSource(source TokenSource) Option {
+	return func(client *Client) {
+		if source == nil {
+			return
+		}
+		caching, ok := source.(*CachingTokenSource)
+		if !ok {
+			caching = ReuseTokenSource(source, 0)
+		}
+		client.tokens = caching
+	}
+}
+
+// authorizedAttempt sends one attempt with a bearer token and repeats it once
+// with a fresh token after 401 Unauthorized. The original response is
+// returned when no fresh token or body is available.
+func (c *Client) authorizedAttempt(ctx context.Context, spec Request) (*StreamResponse, error) {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.doAttempt(ctx, withAuthorization(spec, token))
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+
+	c.tokens.Invalidate(token.AccessToken)
+	fresh, err := c.tokens.Token(ctx)
+	if err != nil {
+		return response, nil
+	}
+	if spec.Body != nil {
+		body, err := spec.Body()
+		if err != nil {
+			return response, nil
+		}
+		var once sync.Once
+		reopen := spec.Body
+		spec.Body = func() (io.ReadCloser, error) {
+			opened := io.ReadCloser(nil)
+			once.Do(func() { opened = body })
+			if opened == nil {
+				return reopen()
+			}
+			return opened, nil
+		}
+	}
+	drainAndClose(response.Body)
+	return c.doAttempt(ctx, withAuthorization(spec, fresh))
+}
+
+func withAuthorization(spec Request, token *Token) Request {
+	spec.Header = spec.Header.Clone()
+	if spec.Header == nil {
+		spec.Header = make(http.Header)
+	}
+	spec.Header.Set("Authorization", token.authorization())
+	return spec
+}
+
+type tokenResponse struct {
+	AccessToken      string          `json:"access_token"`
+	TokenType        string          `json:"token_type"`
+	RefreshToken     string          `json:"refresh_token"`
+	ExpiresIn        json.RawMessage `json:"expires_in"`
+	Error            string          `json:"error"`
+	ErrorDescription string          `json:"error_description"`
+}
+
+func requestToken(
+	ctx context.Context,
+	client *Client,
+	tokenURL string,
+	clientID string,
+	clientSecret string,
+	form url.Values,
+) (*Token, error) {
+	if client == nil {
+		client = New()
+	}
+	if tokenURL == "" {
+		return nil, errors.New("token URL cannot be empty")
+	}
+	header := http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"Accept":       {"application/json"},
+	}
+	if clientID != "" {
+		credentials := url.QueryEscape(clientID) + ":" + url.QueryEscape(clientSecret)
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	response, err := client.Do(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()), header)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded tokenResponse
+	decodeErr := json.Unmarshal(response.Body, &decoded)
+	if !response.OK() || decoded.Error != "" {
+		tokenErr := &TokenError{
+			StatusCode:  response.StatusCode,
+			Code:        decoded.Error,
+			Description: decoded.ErrorDescription,
+		}
+		if tokenErr.Code == "" {
+			tokenErr.Code = http.StatusText(response.StatusCode)
+		}
+		return nil, tokenErr
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	if decoded.AccessToken == "" {
+		return nil, ErrNoAccessToken
+	}
+	token := &Token{
+		AccessToken:  decoded.AccessToken,
+		TokenType:    decoded.TokenType,
+		RefreshToken: decoded.RefreshToken,
+	}
+	seconds, err := strconv.ParseInt(strings.Trim(string(decoded.ExpiresIn), `"`), 10, 64)
+	if err == nil && seconds > 0 {
+		token.Expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return token, nil
+}
@@ -0,0 +1,172 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientCredentialsSingleFlightAndUnauthorizedRetry(t *testing.T) {
+	var issued atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "s3cret" {
+			t.Errorf("basic auth = %q, %q, %v", id, secret, ok)
+		}
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "read write" {
+			t.Errorf("form = %v, error = %v", r.Form, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, issued.Add(1))
+	}))
+	defer tokenServer.Close()
+
+	var rejected atomic.Bool
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost && string(data) != "payload" {
+			t.Errorf("body = %q", data)
+		}
+		if r.Header.Get("Authorization") == "Bearer token-1" && r.URL.Path == "/revoked" && rejected.CompareAndSwap(false, true) {
+			http.Error(w, "expired", http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, r.Header.Get("Authorization"))
+	}))
+	defer api.Close()
+
+	client := New(WithTokenSource(&ClientCredentials{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "client",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read", "write"},
+	}))
+	var group sync.WaitGroup
+	for range 10 {
+		group.Go(func() {
+			response, err := client.Do(context.Background(), http.MethodGet, api.URL, nil, nil)
+			if err != nil || string(response.Body) != "Bearer token-1" {
+				t.Errorf("response = %+v, error = %v", response, err)
+			}
+		})
+	}
+	group.Wait()
+	if issued.Load() != 1 {
+		t.Fatalf("tokens issued = %d", issued.Load())
+	}
+
+	response, err := client.Do(context.Background(), http.MethodPost, api.URL+"/revoked", strings.NewReader("payload"), nil)
+	if err != nil || response.StatusCode != http.StatusOK || string(response.Body) != "Bearer token-2" {
+		t.Fatalf("response = %+v, error = %v", response, err)
+	}
+}
+
+func TestRefreshTokenSourceRotatesAndReportsErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Form.Get("refresh_token") {
+		case "r1":
+			calls.Add(1)
+			_, _ = io.WriteString(w, `{"access_token":"a1","refresh_token":"r2","expires_in":"60"}`)
+		case "r2":
+			calls.Add(1)
+			_, _ = io.WriteString(w, `{"access_token":"a2","expires_in":60}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":"invalid_grant","error_description":"revoked"}`)
+		}
+	}))
+	defer server.Close()
+
+	source := NewRefreshTokenSource(server.URL, "", "", "r1")
+	first, err := source.Token(context.Background())
+	if err != nil || first.AccessToken != "a1" || first.Expiry.IsZero() {
+		t.Fatalf("first = %+v, error = %v", first, err)
+	}
+	second, err := source.Token(context.Background())
+	if err != nil || second.AccessToken != "a2" || second.RefreshToken != "r2" {
+		t.Fatalf("second = %+v, error = %v", second, err)
+	}
+
+	_, err = NewRefreshTokenSource(server.URL, "", "", "bad").Token(context.Background())
+	var tokenErr *TokenError
+	if !errors.As(err, &tokenErr) || tokenErr.Code != "invalid_grant" || tokenErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+type countingTokenSource struct {
+	calls atomic.Int32
+	ttl   time.Duration
+}
+
+func (s *countingTokenSource) Token(context.Context) (*Token, error) {
+	count := s.calls.Add(1)
+	return &Token{AccessToken: fmt.Sprint("t", count), Expiry: time.Now().Add(s.ttl)}, nil
+}
+
+func TestCachingTokenSourceRefreshesEarly(t *testing.T) {
+	source := &countingTokenSource{ttl: time.Minute}
+	cache := ReuseTokenSource(source, 10*time.Second)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	token, err := cache.Token(context.Background())
+	if err != nil || token.AccessToken != "t1" {
+		t.Fatalf("token = %+v, error = %v", token, err)
+	}
+	now = now.Add(55 * time.Second)
+	token, err = cache.Token(context.Background())
+	if err != nil || token.AccessToken != "t1" {
+		t.Fatalf("early token = %+v, error = %v", token, err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for token.AccessToken == "t1" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		if token, err = cache.Token(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if token.AccessToken != "t2" || source.calls.Load() != 2 {
+		t.Fatalf("refreshed token = %+v, calls = %d", token, source.calls.Load())
+	}
+}
+
+func TestCachingTokenSourceReusesShortLivedTokens(t *testing.T) {
+	source := &countingTokenSource{ttl: 20 * time.Second}
+	cache := ReuseTokenSource(source, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	for range 3 {
+		token, err := cache.Token(context.Background())
+		if err != nil || token.AccessToken != "t1" {
+			t.Fatalf("token = %+v, error = %v", token, err)
+		}
+	}
+	if source.calls.Load() != 1 {
+		t.Fatalf("token endpoint calls = %d, want 1", source.calls.Load())
+	}
+}
+
+func TestCachingTokenSourceReturnsExpiredToken(t *testing.T) {
+	source := &countingTokenSource{ttl: -time.Minute}
+	cache := ReuseTokenSource(source, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	token, err := cache.Token(ctx)
+	if err != nil || token.AccessToken != "t1" || source.calls.Load() != 1 {
+		t.Fatalf("token = %+v, error = %v, calls = %d", token, err, source.calls.Load())
+	}
+}
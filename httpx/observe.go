@@ -0,0 +1,291 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// AttemptEvent describes one request attempt. Durations are zero for phases
+// that did not happen, such as DNS and connect on a reused connection. For an
+// attempt that followed redirects, phase durations describe the last hop.
+// Duration ends when the response headers arrive.
+type AttemptEvent struct {
+	Method          string
+	URL             string
+	Attempt         int
+	StatusCode      int
+	Err             error
+	Start           time.Time
+	DNS             time.Duration
+	Connect         time.Duration
+	TLS             time.Duration
+	TimeToFirstByte time.Duration
+	Duration        time.Duration
+	ReusedConn      bool
+	RemoteAddr      string
+}
+
+// RequestEvent describes the final outcome of a request after retries.
+// Attempts is zero when the response came from the cache.
+type RequestEvent struct {
+	Method     string
+	URL        string
+	Attempts   int
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+}
+
+// Observer receives request events. Methods are called synchronously on the
+// request goroutine and must not block.
+type Observer interface {
+	ObserveAttempt(ctx context.Context, event AttemptEvent)
+	ObserveRequest(ctx context.Context, event RequestEvent)
+}
+
+// WithObserver adds an observer for every attempt and request.
+func WithObserver(observer Observer) Option {
+	return func(client *Client) {
+		if observer != nil {
+			client.observers = append(client.observers, observer)
+		}
+	}
+}
+
+type attemptCounterContextKey struct{}
+
+// observe runs send and reports its outcome to the client's observers.
+func (c *Client) observe(
+	ctx context.Context,
+	request Request,
+	send func(context.Context, Request) (*StreamResponse, error),
+) (*StreamResponse, error) {
+	if len(c.observers) == 0 {
+		return send(ctx, request)
+	}
+	var attempts atomic.Int32
+	start := time.Now()
+	response, err := send(context.WithValue(ctx, attemptCounterContextKey{}, &attempts), request)
+	event := RequestEvent{
+		Method:   request.Method,
+		URL:      request.URL,
+		Attempts: int(attempts.Load()),
+		Err:      err,
+		Duration: time.Since(start),
+	}
+	if response != nil {
+		event.StatusCode = response.StatusCode
+	}
+	for _, observer := range c.observers {
+		observer.ObserveRequest(ctx, event)
+	}
+	return response, err
+}
+
+// traceAttempt installs an httptrace.ClientTrace on ctx. The returned function
+// reports the attempt to the client's observers.
+func (c *Client) traceAttempt(ctx context.Context, spec Request) (context.Context, func(*http.Response, error)) {
+	if len(c.observers) == 0 {
+		return ctx, func(*http.Response, error) {}
+	}
+	if counter, ok := ctx.Value(attemptCounterContextKey{}).(*atomic.Int32); ok {
+		counter.Add(1)
+	}
+	attempt, _ := AttemptFromContext(ctx)
+	var mutex sync.Mutex
+	event := AttemptEvent{Method: spec.Method, URL: spec.URL, Attempt: attempt, Start: time.Now()}
+	var dnsStart, connectStart, tlsStart time.Time
+	record := func(update func()) {
+		mutex.Lock()
+		update()
+		mutex.Unlock()
+	}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { record(func() { dnsStart = time.Now() }) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { record(func() { event.DNS = time.Since(dnsStart) }) },
+		ConnectStart: func(string, string) {
+			record(func() {
+				if connectStart.IsZero() {
+					connectStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			record(func() {
+				if err == nil {
+					event.Connect = time.Since(connectStart)
+				}
+			})
+		},
+		TLSHandshakeStart: func() { record(func() { tlsStart = time.Now() }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			record(func() { event.TLS = time.Since(tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			record(func() {
+				event.ReusedConn = info.Reused
+				if info.Conn != nil {
+					event.RemoteAddr = info.Conn.RemoteAddr().String()
+				}
+			})
+		},
+		GotFirstResponseByte: func() {
+			record(func() { event.TimeToFirstByte = time.Since(event.Start) })
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace), func(response *http.Response, err error) {
+		mutex.Lock()
+		reported := event
+		mutex.Unlock()
+		reported.Duration = time.Since(reported.Start)
+		reported.Err = err
+		if response != nil {
+			reported.StatusCode = response.StatusCode
+		}
+		for _, observer := range c.observers {
+			observer.ObserveAttempt(ctx, reported)
+		}
+	}
+}
+
+// SlogObserver logs attempts at debug level and request outcomes at info
+// level, or warn level when the request failed. URLs are passed through
+// RedactURL.
+type SlogObserver struct {
+	Logger *slog.Logger
+}
+
+// NewSlogObserver returns an observer that logs to logger, or to the default
+// logger when logger is nil.
+func NewSlogObserver(logger *slog.Logger) *SlogObserver {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogObserver{Logger: logger}
+}
+
+func (o *SlogObserver) ObserveAttempt(ctx context.Context, event AttemptEvent) {
+	attributes := []slog.Attr{
+		slog.String("method", event.Method),
+		slog.String("url", RedactURL(event.URL)),
+		slog.Int("attempt", event.Attempt),
+		slog.Int("status", event.StatusCode),
+		slog.Duration("duration", event.Duration),
+		slog.Duration("dns", event.DNS),
+		slog.Duration("connect", event.Connect),
+		slog.Duration("tls", event.TLS),
+		slog.Duration("ttfb", event.TimeToFirstByte),
+		slog.Bool("reused", event.ReusedConn),
+	}
+	if event.Err != nil {
+		attributes = append(attributes, slog.String("error", event.Err.Error()))
+	}
+	o.Logger.LogAttrs(ctx, slog.LevelDebug, "http attempt", attributes...)
+}
+
+func (o *SlogObserver) ObserveRequest(ctx context.Context, event RequestEvent) {
+	level := slog.LevelInfo
+	attributes := []slog.Attr{
+		slog.String("method", event.Method),
+		slog.String("url", RedactURL(event.URL)),
+		slog.Int("status", event.StatusCode),
+		slog.Int("attempts", event.Attempts),
+		slog.Duration("duration", event.Duration),
+	}
+	if event.Err != nil {
+		level = slog.LevelWarn
+		attributes = append(attributes, slog.String("error", event.Err.Error()))
+	}
+	o.Logger.LogAttrs(ctx, level, "http request", attributes...)
+}
+
+const redactedValue = "REDACTED"
+
+// sensitiveQueryNames are query parameter names, or words of them, whose
+// values RedactURL hides.
+var sensitiveQueryNames = []string{
+	"token", "key", "secret", "password", "passwd", "signature", "sig", "auth", "credential", "session", "code",
+	"apikey", "accesstoken", "sessionid", "jwt",
+}
+
+// RedactURL hides the password in user information and the values of query
+// parameters whose names suggest credentials, such as access_token, api_key,
+// and X-Amz-Signature. Names are matched case-insensitively, as a whole or by
+// the words separated by punctuation or a change of case, so zipcode and
+// keyword are left alone. Unparseable input is replaced entirely.
+func RedactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return redactedValue
+	}
+	if parsed.User != nil {
+		if _, ok := parsed.User.Password(); ok {
+			parsed.User = url.UserPassword(parsed.User.Username(), redactedValue)
+		}
+	}
+	if parsed.RawQuery != "" {
+		query := parsed.Query()
+		changed := false
+		for name, values := range query {
+			if !sensitiveName(name) {
+				continue
+			}
+			for index := range values {
+				values[index] = redactedValue
+			}
+			changed = true
+		}
+		if changed {
+			parsed.RawQuery = query.Encode()
+		}
+	}
+	return parsed.String()
+}
+
+func sensitiveName(name string) bool {
+	words := append(nameWords(name), strings.ToLower(name))
+	for _, word := range words {
+		if slices.Contains(sensitiveQueryNames, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameWords splits a parameter name such as X-Amz-Signature, api_key, or
+// accessToken into lower-case words.
+func nameWords(name string) []string {
+	var words []string
+	var word []rune
+	previousLower := false
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	for _, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			previousLower = false
+			continue
+		case unicode.IsUpper(r) && previousLower:
+			flush()
+		}
+		word = append(word, r)
+		previousLower = unicode.IsLower(r) || unicode.IsDigit(r)
+	}
+	flush()
+	return words
+}
@@ -0,0 +1,219 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultMaxPages = 1000
+
+var ErrTooManyPages = errors.New("pagination exceeded the page limit")
+
+// Pager returns the URL of the page after current, or nil when page is the
+// last one. Header is the response header of current.
+type Pager[P any] func(current *url.URL, header http.Header, page P) *url.URL
+
+// Pagination configures Paginate. Items extracts the items of a decoded page.
+// MaxPages stops a runaway listing with ErrTooManyPages; zero uses 1000 and a
+// negative value removes the limit. Header is sent with every page request.
+type Pagination[P, T any] struct {
+	Pager    Pager[P]
+	Items    func(P) []T
+	MaxPages int
+	Header   http.Header
+}
+
+// Paginate requests the pages of a JSON listing starting at rawURL and
+// yields their items in order. Errors are yielded once, with the zero item,
+// and end the iteration. The context is checked before every page request,
+// so cancelling it stops the iteration between pages; breaking out of the
+// loop stops it without requesting further pages.
+func Paginate[P, T any](ctx context.Context, client *Client, rawURL string, pagination Pagination[P, T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if pagination.Pager == nil || pagination.Items == nil {
+			yield(zero, errors.New("pagination requires a pager and an item extractor"))
+			return
+		}
+		maxPages := pagination.MaxPages
+		if maxPages == 0 {
+			maxPages = defaultMaxPages
+		}
+		current, err := url.Parse(rawURL)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		for page := 1; current != nil; page++ {
+			if maxPages > 0 && page > maxPages {
+				yield(zero, fmt.Errorf("%w: %d pages", ErrTooManyPages, maxPages))
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+			decoded, response, err := exchangeJSON[any, P](ctx, client, http.MethodGet, current.String(), nil, pagination.Header)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range pagination.Items(decoded) {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			current = pagination.Pager(current, response.Header, decoded)
+		}
+	}
+}
+
+// PaginateLinks pages through a listing whose pages are JSON arrays linked by
+// RFC 8288 Link headers with rel="next".
+func PaginateLinks[T any](ctx context.Context, client *Client, rawURL string) iter.Seq2[T, error] {
+	return Paginate(ctx, client, rawURL, Pagination[[]T, T]{
+		Pager: LinkPager[[]T](),
+		Items: func(page []T) []T { return page },
+	})
+}
+
+// LinkPager follows the Link header target with relation type "next",
+// resolved against the current URL.
+func LinkPager[P any]() Pager[P] {
+	return func(current *url.URL, header http.Header, _ P) *url.URL {
+		for _, link := range parseLinkHeader(header.Values("Link")) {
+			if !link.has("next") {
+				continue
+			}
+			next, err := current.Parse(link.target)
+			if err != nil {
+				return nil
+			}
+			return next
+		}
+		return nil
+	}
+}
+
+// CursorPager sets query parameter param to the cursor found in each page.
+// An empty cursor ends the listing.
+func CursorPager[P any](param string, cursor func(P) string) Pager[P] {
+	return func(current *url.URL, _ http.Header, page P) *url.URL {
+		token := cursor(page)
+		if token == "" {
+			return nil
+		}
+		return withQuery(current, param, token)
+	}
+}
+
+// OffsetPager advances query parameter offsetParam by the number of items in
+// each page. The listing ends with an empty page or, when limit is positive,
+// with a page shorter than limit. A positive limit is also set in limitParam
+// on the URLs the pager builds; the pager never sees the first request, so
+// the caller must put the limit on the initial URL, for example
+// "/items?limit=50".
+func OffsetPager[P any](offsetParam, limitParam string, limit int, count func(P) int) Pager[P] {
+	return func(current *url.URL, _ http.Header, page P) *url.URL {
+		items := count(page)
+		if items <= 0 || (limit > 0 && items < limit) {
+			return nil
+		}
+		offset, _ := strconv.Atoi(current.Query().Get(offsetParam))
+		next := withQuery(current, offsetParam, strconv.Itoa(offset+items))
+		if limit > 0 && limitParam != "" {
+			next = withQuery(next, limitParam, strconv.Itoa(limit))
+		}
+		return next
+	}
+}
+
+func withQuery(target *url.URL, key, value string) *url.URL {
+	next := *target
+	query := next.Query()
+	query.Set(key, value)
+	next.RawQuery = query.Encode()
+	return &next
+}
+
+type linkValue struct {
+	target string
+	rels   []string
+}
+
+func (l linkValue) has(rel string) bool {
+	for _, candidate := range l.rels {
+		if strings.EqualFold(candidate, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLinkHeader parses RFC 8288 link values. Targets may contain commas,
+// so values are split only outside angle brackets and quoted strings.
+func parseLinkHeader(values []string) []linkValue {
+	var links []linkValue
+	for _, value := range values {
+		for len(value) > 0 {
+			start := strings.IndexByte(value, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(value[start:], '>')
+			if end < 0 {
+				break
+			}
+			link := linkValue{target: strings.TrimSpace(value[start+1 : start+end])}
+			rest := value[start+end+1:]
+			params, remainder := splitLinkParams(rest)
+			for _, param := range params {
+				name, argument, _ := strings.Cut(param, "=")
+				if strings.EqualFold(strings.TrimSpace(name), "rel") {
+					link.rels = strings.Fields(strings.Trim(strings.TrimSpace(argument), `"`))
+				}
+			}
+			links = append(links, link)
+			value = remainder
+		}
+	}
+	return links
+}
+
+// splitLinkParams returns the ";"-separated parameters of one link value and
+// the text after the comma that ends it.
+func splitLinkParams(value string) ([]string, string) {
+	var params []string
+	quoted := false
+	begin := 0
+	for index := 0; index < len(value); index++ {
+		switch value[index] {
+		case '"':
+			quoted = !quoted
+		case ';', ',':
+			if quoted {
+				continue
+			}
+			if param := strings.TrimSpace(value[begin:index]); param != "" {
+				params = append(params, param)
+			}
+			if value[index] == ',' {
+				return params, value[index+1:]
+			}
+			begin = index + 1
+		}
+	}
+	if param := strings.TrimSpace(value[begin:]); param != "" {
+		params = append(params, param)
+	}
+	return params, ""
+}
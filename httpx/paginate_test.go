@@ -0,0 +1,135 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPaginateLinks(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 2 {
+			w.Header().Add("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=9,x>; rel="last"`, page+1))
+		}
+		_ = json.NewEncoder(w).Encode([]int{page*10 + 1, page*10 + 2})
+	}))
+	defer server.Close()
+
+	var got []int
+	for item, err := range PaginateLinks[int](context.Background(), New(), server.URL+"/items?page=0") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, item)
+	}
+	if fmt.Sprint(got) != "[1 2 11 12 21 22]" || requests.Load() != 3 {
+		t.Fatalf("items = %v, requests = %d", got, requests.Load())
+	}
+
+	requests.Store(0)
+	for item := range PaginateLinks[int](context.Background(), New(), server.URL+"/items") {
+		if item == 2 {
+			break
+		}
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("requests after break = %d", requests.Load())
+	}
+}
+
+func TestPaginateCursorAndOffset(t *testing.T) {
+	type cursorPage struct {
+		Data []string `json:"data"`
+		Next string   `json:"next_cursor"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch r.URL.Path {
+		case "/cursor":
+			next := map[string]string{"": "b", "b": "c"}[query.Get("cursor")]
+			_ = json.NewEncoder(w).Encode(cursorPage{Data: []string{"item-" + query.Get("cursor")}, Next: next})
+		case "/offset":
+			offset, _ := strconv.Atoi(query.Get("offset"))
+			limit, _ := strconv.Atoi(query.Get("limit"))
+			var items []int
+			for value := offset; value < min(offset+limit, 5); value++ {
+				items = append(items, value)
+			}
+			_ = json.NewEncoder(w).Encode(items)
+		}
+	}))
+	defer server.Close()
+
+	var cursors []string
+	for item, err := range Paginate(context.Background(), New(), server.URL+"/cursor", Pagination[cursorPage, string]{
+		Pager: CursorPager("cursor", func(page cursorPage) string { return page.Next }),
+		Items: func(page cursorPage) []string { return page.Data },
+	}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		cursors = append(cursors, item)
+	}
+	if fmt.Sprint(cursors) != "[item- item-b item-c]" {
+		t.Fatalf("cursor items = %v", cursors)
+	}
+
+	var offsets []int
+	for item, err := range Paginate(context.Background(), New(), server.URL+"/offset?limit=2", Pagination[[]int, int]{
+		Pager: OffsetPager("offset", "limit", 2, func(page []int) int { return len(page) }),
+		Items: func(page []int) []int { return page },
+	}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, item)
+	}
+	if fmt.Sprint(offsets) != "[0 1 2 3 4]" {
+		t.Fatalf("offset items = %v", offsets)
+	}
+}
+
+func TestPaginateStops(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<?again>; rel="next"`)
+		_ = json.NewEncoder(w).Encode([]int{1})
+	}))
+	defer server.Close()
+
+	var pages int
+	var err error
+	for _, err = range Paginate(context.Background(), New(), server.URL, Pagination[[]int, int]{
+		Pager:    LinkPager[[]int](),
+		Items:    func(page []int) []int { return page },
+		MaxPages: 3,
+	}) {
+		if err == nil {
+			pages++
+		}
+	}
+	if !errors.Is(err, ErrTooManyPages) || pages != 3 {
+		t.Fatalf("pages = %d, error = %v", pages, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pages = 0
+	for _, err = range PaginateLinks[int](ctx, New(), server.URL) {
+		if err == nil {
+			pages++
+			cancel()
+		}
+	}
+	if !errors.Is(err, context.Canceled) || pages != 1 {
+		t.Fatalf("pages = %d, error = %v", pages, err)
+	}
+}
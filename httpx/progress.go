@@ -0,0 +1,164 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+const defaultProgressInterval = 250 * time.Millisecond
+
+type ProgressDirection uint8
+
+const (
+	Upload ProgressDirection = iota
+	Download
+)
+
+func (d ProgressDirection) String() string {
+	if d == Upload {
+		return "upload"
+	}
+	return "download"
+}
+
+// Progress reports transfer of one request or response body. Total is -1
+// when the length is unknown, and ETA is zero unless Total and Rate are
+// known. Rate is in bytes per second since the body was opened. A final
+// report is sent once the body reaches EOF or is closed; its Done field tells
+// whether the whole body was transferred.
+type Progress struct {
+	Direction ProgressDirection
+	Attempt   int
+	Bytes     int64
+	Total     int64
+	Rate      float64
+	ETA       time.Duration
+	Elapsed   time.Duration
+	Done      bool
+}
+
+// ProgressOptions receives progress for requests sent with a context from
+// ContextWithProgress. Reports for one body are at least Interval apart,
+// which defaults to 250ms, except for the final report. Every attempt starts
+// again from zero; Attempt tells retries apart.
+type ProgressOptions struct {
+	Interval   time.Duration
+	OnUpload   func(Progress)
+	OnDownload func(Progress)
+}
+
+type progressContextKey struct{}
+
+// ContextWithProgress returns a context that reports the progress of request
+// and response bodies sent with it. Responses served from the cache are not
+// reported.
+func ContextWithProgress(ctx context.Context, options ProgressOptions) context.Context {
+	if options.Interval <= 0 {
+		options.Interval = defaultProgressInterval
+	}
+	return context.WithValue(ctx, progressContextKey{}, &options)
+}
+
+func progressFromContext(ctx context.Context) *ProgressOptions {
+	options, _ := ctx.Value(progressContextKey{}).(*ProgressOptions)
+	return options
+}
+
+// trackProgress wraps body with a reader that reports to report, or returns
+// body unchanged when report is nil.
+func trackProgress(
+	ctx context.Context,
+	body io.ReadCloser,
+	direction ProgressDirection,
+	total int64,
+	interval time.Duration,
+	report func(Progress),
+) io.ReadCloser {
+	if body == nil || report == nil {
+		return body
+	}
+	if total <= 0 {
+		total = -1
+	}
+	attempt, _ := AttemptFromContext(ctx)
+	now := time.Now()
+	return &progressReader{
+		body:     body,
+		report:   report,
+		interval: interval,
+		progress: Progress{Direction: direction, Attempt: attempt, Total: total},
+		started:  now,
+		reported: now,
+	}
+}
+
+type progressReader struct {
+	body     io.ReadCloser
+	report   func(Progress)
+	interval time.Duration
+
+	mutex    sync.Mutex
+	progress Progress
+	started  time.Time
+	reported time.Time
+	finished bool
+}
+
+func (r *progressReader) Read(target []byte) (int, error) {
+	count, err := r.body.Read(target)
+	r.mutex.Lock()
+	r.progress.Bytes += int64(count)
+	now := time.Now()
+	var report *Progress
+	switch {
+	case errors.Is(err, io.EOF):
+		report = r.finish(now, true)
+	case count > 0 && now.Sub(r.reported) >= r.interval:
+		r.reported = now
+		snapshot := r.snapshot(now)
+		report = &snapshot
+	}
+	r.mutex.Unlock()
+	if report != nil {
+		r.report(*report)
+	}
+	return count, err
+}
+
+func (r *progressReader) Close() error {
+	err := r.body.Close()
+	r.mutex.Lock()
+	report := r.finish(time.Now(), false)
+	r.mutex.Unlock()
+	if report != nil {
+		r.report(*report)
+	}
+	return err
+}
+
+// finish returns the final report once. It must be called with the mutex
+// held.
+func (r *progressReader) finish(now time.Time, done bool) *Progress {
+	if r.finished {
+		return nil
+	}
+	r.finished = true
+	snapshot := r.snapshot(now)
+	snapshot.Done = done || (snapshot.Total > 0 && snapshot.Bytes == snapshot.Total)
+	return &snapshot
+}
+
+func (r *progressReader) snapshot(now time.Time) Progress {
+	progress := r.progress
+	progress.Elapsed = now.Sub(r.started)
+	if seconds := progress.Elapsed.Seconds(); seconds > 0 {
+		progress.Rate = float64(progress.Bytes) / seconds
+	}
+	if progress.Total > 0 && progress.Rate > 0 && progress.Bytes < progress.Total {
+		progress.ETA = time.Duration(float64(progress.Total-progress.Bytes) / progress.Rate * float64(time.Second))
+	}
+	return progress
+}
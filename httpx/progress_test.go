@@ -0,0 +1,99 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProgressResetsForEveryUploadAttempt(t *testing.T) {
+	var requests atomic.Int32
+	var received sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		attempt := requests.Add(1)
+		received.Store(int(attempt), int64(len(data)))
+		if attempt == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "stored")
+	}))
+	defer server.Close()
+
+	form := NewMultipart()
+	if err := form.AddBytes("file", "deck.pptx", []byte(strings.Repeat("slide", 4096))); err != nil {
+		t.Fatal(err)
+	}
+	var mutex sync.Mutex
+	final := make(map[int]Progress)
+	var uploads int
+	ctx := ContextWithProgress(context.Background(), ProgressOptions{
+		Interval: time.Nanosecond,
+		OnUpload: func(progress Progress) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			uploads++
+			if progress.Direction != Upload || progress.Total != -1 {
+				t.Errorf("progress = %+v", progress)
+			}
+			if progress.Done {
+				final[progress.Attempt] = progress
+			}
+		},
+	})
+	client := New(WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, Methods: []string{http.MethodPost}}))
+	response, err := client.PostMultipart(ctx, server.URL, form, nil)
+	if err != nil || string(response.Body) != "stored" {
+		t.Fatalf("response = %+v, error = %v", response, err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for attempt := 1; attempt <= 2; attempt++ {
+		size, _ := received.Load(attempt)
+		if final[attempt].Bytes != size.(int64) {
+			t.Errorf("attempt %d final = %+v, server received %d", attempt, final[attempt], size)
+		}
+	}
+	if uploads < 4 {
+		t.Fatalf("uploads reports = %d", uploads)
+	}
+}
+
+func TestProgressThrottlesDownloadReports(t *testing.T) {
+	body := strings.Repeat("x", 64<<10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		for index := 0; index < len(body); index += 4096 {
+			_, _ = io.WriteString(w, body[index:index+4096])
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	var reports []Progress
+	ctx := ContextWithProgress(context.Background(), ProgressOptions{
+		Interval:   time.Hour,
+		OnDownload: func(progress Progress) { reports = append(reports, progress) },
+	})
+	response, err := New().Do(ctx, http.MethodGet, server.URL, nil, nil)
+	if err != nil || len(response.Body) != len(body) {
+		t.Fatalf("response error = %v", err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("reports = %+v", reports)
+	}
+	last := reports[0]
+	if !last.Done || last.Attempt != 1 || last.Bytes != int64(len(body)) || last.Total != int64(len(body)) ||
+		last.Direction != Download || last.ETA != 0 {
+		t.Fatalf("final report = %+v", last)
+	}
+}
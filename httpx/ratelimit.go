@@ -0,0 +1,323 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit describes one throttle. Rate is in requests per second and Burst
+// is the bucket size, defaulting to one. MaxInFlight caps concurrent requests;
+// a response counts as in flight until its body is closed. Zero values leave
+// that dimension unlimited.
+type RateLimit struct {
+	Rate        float64
+	Burst       int
+	MaxInFlight int
+}
+
+// RateLimitPolicy configures outbound throttling. Global is shared by every
+// request, PerHost applies separately to each host, and Hosts overrides
+// PerHost for specific hosts. An entry with a port matches URL.Host;
+// otherwise it matches Hostname.
+//
+// With AdaptFromHeaders, a host is paused after a 429 or 503 response with
+// Retry-After, or after a response reporting no remaining quota through the
+// RateLimit-Remaining and RateLimit-Reset headers or the combined RateLimit
+// header. MaxPause bounds how long such a pause may last and defaults to one
+// minute.
+type RateLimitPolicy struct {
+	Global           RateLimit
+	PerHost          RateLimit
+	Hosts            map[string]RateLimit
+	AdaptFromHeaders bool
+	MaxPause         time.Duration
+}
+
+// WithRateLimit throttles every request attempt, including retries. Waiting
+// for a token or a free slot honours the request context.
+func WithRateLimit(policy RateLimitPolicy) Option {
+	return func(client *Client) {
+		client.limiter = newRateLimiter(policy)
+	}
+}
+
+// hostLimiterSweep is the number of tracked hosts above which idle host
+// limiters are dropped. The threshold doubles with the hosts still in use, so
+// sweeping stays amortized constant time per new host.
+const hostLimiterSweep = 64
+
+type rateLimiter struct {
+	mutex   sync.Mutex
+	policy  RateLimitPolicy
+	global  *hostLimiter
+	hosts   map[string]*hostLimiter
+	sweepAt int
+	now     func() time.Time
+}
+
+type hostLimiter struct {
+	mutex       sync.Mutex
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	slots       chan struct{}
+	initialized bool
+	// users counts acquisitions not yet released. It is guarded by the
+	// rateLimiter mutex, not by mutex.
+	users int
+}
+
+func newRateLimiter(policy RateLimitPolicy) *rateLimiter {
+	hosts := make(map[string]RateLimit, len(policy.Hosts))
+	for host, limit := range policy.Hosts {
+		hosts[strings.ToLower(strings.TrimSpace(host))] = limit
+	}
+	policy.Hosts = hosts
+	if policy.MaxPause <= 0 {
+		policy.MaxPause = time.Minute
+	}
+	return &rateLimiter{
+		policy:  policy,
+		global:  newHostLimiter(policy.Global),
+		hosts:   make(map[string]*hostLimiter),
+		sweepAt: hostLimiterSweep,
+		now:     time.Now,
+	}
+}
+
+func newHostLimiter(limit RateLimit) *hostLimiter {
+	limiter := &hostLimiter{}
+	if limit.Rate > 0 {
+		limiter.rate = limit.Rate
+		limiter.burst = float64(max(limit.Burst, 1))
+		limiter.tokens = limiter.burst
+	}
+	if limit.MaxInFlight > 0 {
+		limiter.slots = make(chan struct{}, limit.MaxInFlight)
+	}
+	return limiter
+}
+
+func (l *rateLimiter) host(rawURL string) *hostLimiter {
+	host := requestHost(rawURL)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if limiter, ok := l.hosts[host]; ok {
+		limiter.users++
+		return limiter
+	}
+	if len(l.hosts) >= l.sweepAt {
+		l.sweep()
+	}
+	limit, ok := l.policy.Hosts[host]
+	if !ok {
+		hostname, _, err := net.SplitHostPort(host)
+		if err != nil {
+			hostname = host
+		}
+		limit, ok = l.policy.Hosts[strings.Trim(hostname, "[]")]
+	}
+	if !ok {
+		limit = l.policy.PerHost
+	}
+	limiter := newHostLimiter(limit)
+	limiter.users = 1
+	l.hosts[host] = limiter
+	return limiter
+}
+
+func (l *rateLimiter) leave(limiter *hostLimiter) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	limiter.users--
+}
+
+// sweep drops host limiters that nobody holds and that are back in their
+// initial state, so recreating them later changes nothing. The caller holds
+// the mutex.
+func (l *rateLimiter) sweep() {
+	now := l.now()
+	for host, limiter := range l.hosts {
+		if limiter.users == 0 && limiter.idle(now) {
+			delete(l.hosts, host)
+		}
+	}
+	l.sweepAt = max(2*len(l.hosts), hostLimiterSweep)
+}
+
+// acquire waits for the host and then the global limits, so a saturated host
+// does not hold global slots. A failed wait returns the tokens already
+// reserved. The returned release function frees in-flight slots and is safe to
+// call more than once.
+func (l *rateLimiter) acquire(ctx context.Context, rawURL string) (*hostLimiter, func(), error) {
+	host := l.host(rawURL)
+	releases := make([]func(), 0, 3)
+	releases = append(releases, func() { l.leave(host) })
+	release := func() {
+		for index := len(releases) - 1; index >= 0; index-- {
+			releases[index]()
+		}
+	}
+	limiters := []*hostLimiter{host, l.global}
+	for index, limiter := range limiters {
+		done, err := limiter.wait(ctx, l.now)
+		if err != nil {
+			for _, reserved := range limiters[:index] {
+				reserved.cancel()
+			}
+			release()
+			return nil, nil, err
+		}
+		releases = append(releases, done)
+	}
+	var once sync.Once
+	return host, func() { once.Do(release) }, nil
+}
+
+func (h *hostLimiter) wait(ctx context.Context, now func() time.Time) (func(), error) {
+	delay := h.reserve(now())
+	if delay > 0 {
+		if err := waitForRetry(ctx, delay); err != nil {
+			h.cancel()
+			return nil, err
+		}
+	}
+	if h.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case h.slots <- struct{}{}:
+		return func() { <-h.slots }, nil
+	case <-ctx.Done():
+		h.cancel()
+		return nil, ctx.Err()
+	}
+}
+
+func (h *hostLimiter) reserve(now time.Time) time.Duration {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var delay time.Duration
+	if h.rate > 0 {
+		if h.initialized {
+			h.tokens = math.Min(h.burst, h.tokens+now.Sub(h.last).Seconds()*h.rate)
+		}
+		h.initialized = true
+		h.last = now
+		h.tokens--
+		if h.tokens < 0 {
+			delay = time.Duration(-h.tokens / h.rate * float64(time.Second))
+		}
+	}
+	if pause := h.pausedUntil.Sub(now); pause > delay {
+		delay = pause
+	}
+	return delay
+}
+
+func (h *hostLimiter) cancel() {
+	if h.rate <= 0 {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.tokens = math.Min(h.burst, h.tokens+1)
+}
+
+// idle reports whether the bucket has refilled and no pause is pending.
+func (h *hostLimiter) idle(now time.Time) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if now.Before(h.pausedUntil) || len(h.slots) > 0 {
+		return false
+	}
+	return h.rate <= 0 || !h.initialized || h.tokens+now.Sub(h.last).Seconds()*h.rate >= h.burst
+}
+
+func (h *hostLimiter) pause(until time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if until.After(h.pausedUntil) {
+		h.pausedUntil = until
+	}
+}
+
+// observe pauses host when the response reports an exhausted quota.
+func (l *rateLimiter) observe(host *hostLimiter, statusCode int, header http.Header) {
+	if !l.policy.AdaptFromHeaders || host == nil || header == nil {
+		return
+	}
+	now := l.now()
+	delay, ok := time.Duration(0), false
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable {
+		delay, ok = parseRetryAfter(header.Get("Retry-After"), now)
+	}
+	if !ok {
+		delay, ok = parseRateLimitHeaders(header)
+	}
+	if !ok || delay <= 0 {
+		return
+	}
+	host.pause(now.Add(min(delay, l.policy.MaxPause)))
+}
+
+// parseRateLimitHeaders returns the reset delay when the server reports no
+// remaining quota. Both the RateLimit-Remaining/RateLimit-Reset pair and the
+// combined "RateLimit: limit=10, remaining=0, reset=5" form are recognized.
+func parseRateLimitHeaders(header http.Header) (time.Duration, bool) {
+	remaining := strings.TrimSpace(header.Get("RateLimit-Remaining"))
+	reset := strings.TrimSpace(header.Get("RateLimit-Reset"))
+	if combined := header.Get("RateLimit"); combined != "" {
+		for _, item := range strings.FieldsFunc(combined, func(char rune) bool {
+			return char == ',' || char == ';'
+		}) {
+			key, value, found := strings.Cut(strings.TrimSpace(item), "=")
+			if !found {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "remaining", "r":
+				remaining = strings.TrimSpace(value)
+			case "reset", "t":
+				reset = strings.TrimSpace(value)
+			}
+		}
+	}
+	if remaining == "" || reset == "" {
+		return 0, false
+	}
+	left, err := strconv.ParseInt(remaining, 10, 64)
+	if err != nil || left > 0 {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(reset, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// releaseOnClose calls release once the body is closed.
+type releaseOnClose struct {
+	body    io.ReadCloser
+	release func()
+}
+
+func (r *releaseOnClose) Read(target []byte) (int, error) {
+	return r.body.Read(target)
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.body.Close()
+	r.release()
+	return err
+}
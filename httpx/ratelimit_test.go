@@ -0,0 +1,167 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimitSpacesRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := New(WithRateLimit(RateLimitPolicy{Global: RateLimit{Rate: 50, Burst: 1}}))
+	started := time.Now()
+	for range 3 {
+		if _, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < 35*time.Millisecond {
+		t.Fatalf("elapsed = %v", elapsed)
+	}
+}
+
+func TestRateLimitMaxInFlightPerHost(t *testing.T) {
+	var current, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		value := current.Add(1)
+		defer current.Add(-1)
+		for {
+			old := peak.Load()
+			if value <= old || peak.CompareAndSwap(old, value) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := New(WithRateLimit(RateLimitPolicy{PerHost: RateLimit{MaxInFlight: 2}}))
+	var group sync.WaitGroup
+	for range 6 {
+		group.Go(func() {
+			if _, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	group.Wait()
+	if peak.Load() != 2 {
+		t.Fatalf("peak in flight = %d", peak.Load())
+	}
+}
+
+func TestRateLimitHonoursContextAndRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := New(WithRateLimit(RateLimitPolicy{AdaptFromHeaders: true}))
+	response, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil || response.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("response = %+v, error = %v", response, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Do(ctx, http.MethodGet, server.URL, nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("paused error = %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("requests = %d", requests.Load())
+	}
+}
+
+func TestParseRateLimitHeaders(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		delay  time.Duration
+		ok     bool
+	}{
+		{http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"5"}}, 5 * time.Second, true},
+		{http.Header{"Ratelimit-Remaining": {"3"}, "Ratelimit-Reset": {"5"}}, 0, false},
+		{http.Header{"Ratelimit": {"limit=10, remaining=0, reset=7"}}, 7 * time.Second, true},
+		{http.Header{"Ratelimit": {`"default";r=0;t=2`}}, 2 * time.Second, true},
+		{http.Header{}, 0, false},
+	}
+	for _, test := range tests {
+		delay, ok := parseRateLimitHeaders(test.header)
+		if delay != test.delay || ok != test.ok {
+			t.Errorf("parseRateLimitHeaders(%v) = %v, %v", test.header, delay, ok)
+		}
+	}
+}
+
+func TestRateLimitCancelledWaitReturnsTokens(t *testing.T) {
+	started := time.Now()
+	limiter := newRateLimiter(RateLimitPolicy{
+		Global:  RateLimit{MaxInFlight: 1},
+		PerHost: RateLimit{Rate: 1, Burst: 2, MaxInFlight: 1},
+	})
+	limiter.now = func() time.Time { return started }
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	host, release, err := limiter.acquire(context.Background(), "http://a.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := limiter.acquire(cancelled, "http://a.example/"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("host slot error = %v", err)
+	}
+	if host.tokens != 1 {
+		t.Fatalf("tokens after cancelled slot wait = %v", host.tokens)
+	}
+	release()
+
+	globalDone, err := limiter.global.wait(context.Background(), limiter.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer globalDone()
+	if _, _, err := limiter.acquire(cancelled, "http://a.example/"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("global slot error = %v", err)
+	}
+	if host.tokens != 1 || len(host.slots) != 0 {
+		t.Fatalf("tokens = %v, slots = %d after cancelled global wait", host.tokens, len(host.slots))
+	}
+}
+
+func TestRateLimitEvictsIdleHosts(t *testing.T) {
+	clock := time.Now()
+	limiter := newRateLimiter(RateLimitPolicy{PerHost: RateLimit{Rate: 1}})
+	limiter.now = func() time.Time { return clock }
+
+	busy, releaseBusy, err := limiter.acquire(context.Background(), "http://busy.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index := range 10 * hostLimiterSweep {
+		_, release, err := limiter.acquire(context.Background(), fmt.Sprintf("http://%d.example/", index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+		clock = clock.Add(time.Second)
+	}
+	if len(limiter.hosts) > hostLimiterSweep {
+		t.Fatalf("tracked hosts = %d", len(limiter.hosts))
+	}
+	if limiter.hosts["busy.example"] != busy {
+		t.Fatal("host in use was evicted")
+	}
+	releaseBusy()
+}
@@ -0,0 +1,129 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const defaultMaxRedirects = 10
+
+var (
+	ErrTooManyRedirects   = errors.New("too many redirects")
+	ErrRedirectNotAllowed = errors.New("redirect target is not allowed")
+)
+
+var defaultSensitiveFields = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// Redirect is one hop of a followed redirect chain: URL answered with
+// StatusCode and sent the client to Location.
+type Redirect struct {
+	URL        string
+	StatusCode int
+	Location   string
+}
+
+// RedirectPolicy controls how redirects are followed. MaxRedirects defaults
+// to 10; a negative value disables redirects and returns the 3xx response.
+// SameHostOnly refuses redirects to another host or port. ValidateRedirects
+// runs the client's request validators on every redirect target.
+// Authorization, Cookie, Proxy-Authorization, and the fields in
+// SensitiveHeaders are removed from requests to an origin other than the
+// original one unless ForwardSensitiveHeaders is set.
+type RedirectPolicy struct {
+	MaxRedirects            int
+	SameHostOnly            bool
+	ValidateRedirects       bool
+	SensitiveHeaders        []string
+	ForwardSensitiveHeaders bool
+}
+
+// RedirectError reports a redirect refused by a RedirectPolicy or a request
+// validator.
+type RedirectError struct {
+	URL string
+	Err error
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("redirect to %s: %v", e.URL, e.Err)
+}
+
+func (e *RedirectError) Unwrap() error {
+	return e.Err
+}
+
+// WithRedirectPolicy installs policy as the CheckRedirect function of the
+// client's http.Client when New returns, replacing any existing one.
+func WithRedirectPolicy(policy RedirectPolicy) Option {
+	return func(client *Client) {
+		policy.SensitiveHeaders = append(append([]string(nil), defaultSensitiveFields...), policy.SensitiveHeaders...)
+		if policy.MaxRedirects == 0 {
+			policy.MaxRedirects = defaultMaxRedirects
+		}
+		client.redirects = &policy
+	}
+}
+
+// checkRedirect is the http.Client CheckRedirect function for the policy.
+// http.Client copies the headers of the first request into every hop before
+// calling it, so sensitive fields are compared against the original origin.
+func (c *Client) checkRedirect(request *http.Request, via []*http.Request) error {
+	policy := c.redirects
+	if policy.MaxRedirects < 0 {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > policy.MaxRedirects {
+		return &RedirectError{URL: request.URL.String(), Err: ErrTooManyRedirects}
+	}
+	original := via[0].URL
+	if policy.SameHostOnly && !strings.EqualFold(request.URL.Host, original.Host) {
+		return &RedirectError{URL: request.URL.String(), Err: ErrRedirectNotAllowed}
+	}
+	if !policy.ForwardSensitiveHeaders && !sameOrigin(request, via[0]) {
+		for _, field := range policy.SensitiveHeaders {
+			request.Header.Del(field)
+		}
+	}
+	if policy.ValidateRedirects {
+		for _, validator := range c.requestValidators {
+			if err := validator(request); err != nil {
+				return &RedirectError{URL: request.URL.String(), Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+func sameOrigin(request, original *http.Request) bool {
+	return strings.EqualFold(request.URL.Scheme, original.URL.Scheme) &&
+		strings.EqualFold(request.URL.Hostname(), original.URL.Hostname()) &&
+		urlPort(request) == urlPort(original)
+}
+
+func urlPort(request *http.Request) string {
+	if port := request.URL.Port(); port != "" {
+		return port
+	}
+	if strings.EqualFold(request.URL.Scheme, "https") {
+		return "443"
+	}
+	return "80"
+}
+
+// redirectChain rebuilds the followed redirects from the links http.Client
+// leaves between each request and the response that caused it.
+func redirectChain(response *http.Response) []Redirect {
+	var chain []Redirect
+	for request := response.Request; request != nil && request.Response != nil; request = request.Response.Request {
+		hop := Redirect{StatusCode: request.Response.StatusCode, Location: request.URL.String()}
+		if request.Response.Request != nil {
+			hop.URL = request.Response.Request.URL.String()
+		}
+		chain = append(chain, hop)
+	}
+	slices.Reverse(chain)
+	return chain
+}
@@ -0,0 +1,93 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedirectPolicyStripsCredentialsAcrossOrigins(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "auth="+r.Header.Get("Authorization")+" trace="+r.Header.Get("X-Trace"))
+	}))
+	defer other.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/hop", http.StatusFound)
+		case "/hop":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				t.Errorf("same-origin hop authorization = %q", r.Header.Get("Authorization"))
+			}
+			http.Redirect(w, r, other.URL+"/final", http.StatusTemporaryRedirect)
+		}
+	}))
+	defer origin.Close()
+
+	client := New(WithRedirectPolicy(RedirectPolicy{}))
+	response, err := client.Do(context.Background(), http.MethodGet, origin.URL+"/start", nil,
+		http.Header{"Authorization": {"Bearer secret"}, "X-Trace": {"abc"}})
+	if err != nil || string(response.Body) != "auth= trace=abc" {
+		t.Fatalf("response = %+v, error = %v", response, err)
+	}
+	want := []Redirect{
+		{URL: origin.URL + "/start", StatusCode: http.StatusFound, Location: origin.URL + "/hop"},
+		{URL: origin.URL + "/hop", StatusCode: http.StatusTemporaryRedirect, Location: other.URL + "/final"},
+	}
+	if len(response.Redirects) != len(want) {
+		t.Fatalf("redirects = %+v", response.Redirects)
+	}
+	for index := range want {
+		if response.Redirects[index] != want[index] {
+			t.Errorf("redirect %d = %+v, want %+v", index, response.Redirects[index], want[index])
+		}
+	}
+}
+
+func TestRedirectPolicyRefusesHops(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect target must not be requested")
+	}))
+	defer other.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/away":
+			http.Redirect(w, r, other.URL, http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer origin.Close()
+
+	originHost := strings.TrimPrefix(origin.URL, "http://")
+	tests := []struct {
+		name   string
+		policy RedirectPolicy
+		path   string
+		want   error
+	}{
+		{"same host only", RedirectPolicy{SameHostOnly: true}, "/away", ErrRedirectNotAllowed},
+		{"max redirects", RedirectPolicy{MaxRedirects: 3}, "/loop", ErrTooManyRedirects},
+		{"validators", RedirectPolicy{ValidateRedirects: true}, "/away", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := New(WithRequestValidator(AllowHosts(originHost)), WithRedirectPolicy(test.policy))
+			_, err := client.Do(context.Background(), http.MethodGet, origin.URL+test.path, nil, nil)
+			var redirectErr *RedirectError
+			if !errors.As(err, &redirectErr) || (test.want != nil && !errors.Is(err, test.want)) {
+				t.Fatalf("error = %v", err)
+			}
+		})
+	}
+
+	client := New(WithRedirectPolicy(RedirectPolicy{MaxRedirects: -1}))
+	response, err := client.Do(context.Background(), http.MethodGet, origin.URL+"/away", nil, nil)
+	if err != nil || response.StatusCode != http.StatusFound || len(response.Redirects) != 0 {
+		t.Fatalf("response = %+v, error = %v", response, err)
+	}
+}
@@ -3,19 +3,39 @@ package httpx
 import (
 	"context"
 	"errors"
+	"math/rand/v2"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-var ErrBodyNotReplayable = errors.New("request body cannot be replayed for retry")
+const (
+	defaultRetryBudgetRatio  = 0.1
+	defaultRetryBudgetMin    = 10
+	defaultRetryBudgetWindow = 10 * time.Second
+	retryBudgetBuckets       = 10
+)
+
+var (
+	ErrBodyNotReplayable = errors.New("request body cannot be replayed for retry")
+	ErrAttemptTimeout    = errors.New("http attempt timed out")
+)
 
 // RequestValidator rejects a request before it is sent.
 type RequestValidator func(*http.Request) error
 
 // RetryPolicy controls retries. MaxAttempts includes the initial request.
 // Empty Methods and StatusCodes use conservative defaults.
+//
+// Jitter randomizes the exponential delay; a Retry-After delay is used as
+// given. AttemptTimeout bounds each attempt until its response headers arrive,
+// independently of the context deadline; an attempt that runs out fails with
+// ErrAttemptTimeout, which is retried like other transport errors.
+// ShouldRetry, when set, replaces the StatusCodes and RetryTransportErrors
+// checks. Cancellation, an open circuit, blocked addresses, and redirect
+// policy errors are never retried.
 type RetryPolicy struct {
 	MaxAttempts          int
 	BaseDelay            time.Duration
@@ -24,9 +44,42 @@ type RetryPolicy struct {
 	StatusCodes          []int
 	RetryTransportErrors bool
 	RespectRetryAfter    bool
+	Jitter               Jitter
+	AttemptTimeout       time.Duration
+	ShouldRetry          func(RetryOutcome) bool
 	OnRetry              func(RetryEvent)
 }
 
+// Jitter selects how retry delays are randomized.
+type Jitter uint8
+
+const (
+	// NoJitter uses the exponential delay unchanged.
+	NoJitter Jitter = iota
+	// FullJitter picks a delay between zero and the exponential delay.
+	FullJitter
+	// EqualJitter keeps half of the exponential delay and randomizes the
+	// other half.
+	EqualJitter
+	// DecorrelatedJitter picks a delay between BaseDelay and three times the
+	// previous delay, capped at MaxDelay.
+	DecorrelatedJitter
+)
+
+// RetryOutcome is the result of an attempt as seen by RetryPolicy.ShouldRetry.
+// StatusCode and Header are zero when Err is set.
+type RetryOutcome struct {
+	Attempt    int
+	Method     string
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Err        error
+}
+
+// RetryEvent describes the next attempt. Hedge is set when NextAttempt is a
+// hedged attempt sent while Attempt is still in flight; Delay is then the
+// time Attempt had been waiting.
 type RetryEvent struct {
 	Attempt     int
 	NextAttempt int
@@ -35,6 +88,7 @@ type RetryEvent struct {
 	StatusCode  int
 	Err         error
 	Delay       time.Duration
+	Hedge       bool
 }
 
 func (p RetryPolicy) clone() RetryPolicy {
@@ -95,7 +149,21 @@ func (p RetryPolicy) allowsStatus(statusCode int) bool {
 	return false
 }
 
-func (p RetryPolicy) delay(attempt int, response *http.Response) time.Duration {
+// retryable reports whether an attempt with outcome should be retried,
+// ignoring the attempt limit.
+func (p RetryPolicy) retryable(outcome RetryOutcome) bool {
+	if p.ShouldRetry != nil {
+		return p.ShouldRetry(outcome)
+	}
+	if outcome.Err != nil {
+		return p.RetryTransportErrors
+	}
+	return p.allowsStatus(outcome.StatusCode)
+}
+
+// delay returns the wait before the attempt after attempt. Previous is the
+// delay before attempt, or zero, and only matters for DecorrelatedJitter.
+func (p RetryPolicy) delay(attempt int, previous time.Duration, response *http.Response) time.Duration {
 	if p.RespectRetryAfter && response != nil {
 		if delay, ok := parseRetryAfter(response.Header.Get("Retry-After"), time.Now()); ok {
 			if delay > p.MaxDelay {
@@ -104,6 +172,20 @@ func (p RetryPolicy) delay(attempt int, response *http.Response) time.Duration {
 			return delay
 		}
 	}
+	delay := p.backoff(attempt)
+	switch p.Jitter {
+	case FullJitter:
+		return randomDuration(0, delay)
+	case EqualJitter:
+		return delay/2 + randomDuration(0, delay-delay/2)
+	case DecorrelatedJitter:
+		previous = min(max(previous, p.BaseDelay), p.MaxDelay)
+		return min(randomDuration(p.BaseDelay, previous*3), p.MaxDelay)
+	}
+	return delay
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
 	delay := p.BaseDelay
 	for current := 1; current < attempt && delay < p.MaxDelay; current++ {
 		if delay > p.MaxDelay/2 {
@@ -117,6 +199,14 @@ func (p RetryPolicy) delay(attempt int, response *http.Response) time.Duration {
 	return delay
 }
 
+// randomDuration returns a uniformly random duration in [low, high].
+func randomDuration(low, high time.Duration) time.Duration {
+	if high <= low {
+		return low
+	}
+	return low + rand.N(high-low+1)
+}
+
 func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
 	value = strings.TrimSpace(value)
 	if value == "" {
@@ -154,3 +244,86 @@ func waitForRetry(ctx context.Context, delay time.Duration) error {
 		return nil
 	}
 }
+
+// RetryBudget caps retries across a client so that retries cannot multiply
+// the load on a failing server. Within any Window, which defaults to ten
+// seconds, retries may not exceed MinRetries plus Ratio times the number of
+// requests sent. Ratio defaults to 0.1 and MinRetries to 10; set a negative
+// value for none. Hedged attempts, download resumes, and event stream
+// reconnects count as retries. When the budget is spent, the last response or
+// error is returned without retrying.
+type RetryBudget struct {
+	Ratio      float64
+	MinRetries int
+	Window     time.Duration
+}
+
+// WithRetryBudget limits the retries of all requests sent by the client.
+func WithRetryBudget(budget RetryBudget) Option {
+	return func(client *Client) {
+		if budget.Ratio == 0 {
+			budget.Ratio = defaultRetryBudgetRatio
+		}
+		budget.Ratio = max(budget.Ratio, 0)
+		if budget.MinRetries == 0 {
+			budget.MinRetries = defaultRetryBudgetMin
+		}
+		budget.MinRetries = max(budget.MinRetries, 0)
+		if budget.Window <= 0 {
+			budget.Window = defaultRetryBudgetWindow
+		}
+		client.budget = &retryBudget{budget: budget, now: time.Now}
+	}
+}
+
+// retryBudget counts requests and retries in a window of buckets.
+type retryBudget struct {
+	budget RetryBudget
+	now    func() time.Time
+
+	mutex   sync.Mutex
+	buckets [retryBudgetBuckets]budgetBucket
+}
+
+type budgetBucket struct {
+	epoch    int64
+	requests int
+	retries  int
+}
+
+// current returns the bucket for now, clearing it if it is stale. It must be
+// called with the mutex held.
+func (b *retryBudget) current() (*budgetBucket, int64) {
+	epoch := b.now().UnixNano() / max(int64(b.budget.Window/retryBudgetBuckets), 1)
+	bucket := &b.buckets[epoch%retryBudgetBuckets]
+	if bucket.epoch != epoch {
+		*bucket = budgetBucket{epoch: epoch}
+	}
+	return bucket, epoch
+}
+
+func (b *retryBudget) request() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	bucket, _ := b.current()
+	bucket.requests++
+}
+
+// withdraw records a retry and reports whether the budget allows it.
+func (b *retryBudget) withdraw() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	bucket, epoch := b.current()
+	requests, retries := 0, 0
+	for _, candidate := range b.buckets {
+		if candidate.epoch > epoch-retryBudgetBuckets {
+			requests += candidate.requests
+			retries += candidate.retries
+		}
+	}
+	if float64(retries) >= float64(b.budget.MinRetries)+b.budget.Ratio*float64(requests) {
+		return false
+	}
+	bucket.retries++
+	return true
+}
@@ -0,0 +1,152 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryJitterBounds(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}.normalized()
+	for range 200 {
+		policy.Jitter = FullJitter
+		if delay := policy.delay(3, 0, nil); delay < 0 || delay > 40*time.Millisecond {
+			t.Fatalf("full jitter delay = %v", delay)
+		}
+		policy.Jitter = EqualJitter
+		if delay := policy.delay(3, 0, nil); delay < 20*time.Millisecond || delay > 40*time.Millisecond {
+			t.Fatalf("equal jitter delay = %v", delay)
+		}
+		policy.Jitter = DecorrelatedJitter
+		if delay := policy.delay(3, 500*time.Millisecond, nil); delay < 10*time.Millisecond || delay > time.Second {
+			t.Fatalf("decorrelated jitter delay = %v", delay)
+		}
+		if delay := policy.delay(1, 0, nil); delay < 10*time.Millisecond || delay > 30*time.Millisecond {
+			t.Fatalf("first decorrelated jitter delay = %v", delay)
+		}
+	}
+	policy.Jitter = FullJitter
+	policy.RespectRetryAfter = true
+	response := &http.Response{Header: http.Header{"Retry-After": {"1"}}}
+	if delay := policy.delay(1, 0, response); delay != time.Second {
+		t.Fatalf("Retry-After delay = %v", delay)
+	}
+}
+
+func TestShouldRetrySeesOutcome(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("X-Retryable", "yes")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var outcomes []RetryOutcome
+	client := New(WithRetry(RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		ShouldRetry: func(outcome RetryOutcome) bool {
+			outcomes = append(outcomes, outcome)
+			return outcome.Header.Get("X-Retryable") == "yes"
+		},
+	}))
+	response, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil || response.StatusCode != http.StatusNoContent {
+		t.Fatalf("response = %+v, err = %v", response, err)
+	}
+	if len(outcomes) != 2 || outcomes[0].StatusCode != http.StatusConflict || outcomes[0].Attempt != 1 ||
+		outcomes[1].StatusCode != http.StatusNoContent {
+		t.Fatalf("outcomes = %+v", outcomes)
+	}
+}
+
+func TestAttemptTimeoutRetriesSlowAttempt(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte("slow body"))
+	}))
+	defer server.Close()
+
+	var events []RetryEvent
+	client := New(WithRetry(RetryPolicy{
+		MaxAttempts:          2,
+		BaseDelay:            time.Millisecond,
+		AttemptTimeout:       50 * time.Millisecond,
+		RetryTransportErrors: true,
+		OnRetry:              func(event RetryEvent) { events = append(events, event) },
+	}))
+	response, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil || string(response.Body) != "slow body" {
+		t.Fatalf("response = %+v, err = %v", response, err)
+	}
+	if len(events) != 1 || !errors.Is(events[0].Err, ErrAttemptTimeout) {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func TestRetryBudgetStopsRetries(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := New(
+		WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Microsecond}),
+		WithRetryBudget(RetryBudget{Ratio: 0.5, MinRetries: 1, Window: time.Minute}),
+	)
+	for range 4 {
+		if _, err := client.Do(context.Background(), http.MethodGet, server.URL, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Four requests allow 1 + 0.5*4 = 3 retries in the window.
+	if got := requests.Load(); got != 7 {
+		t.Fatalf("upstream requests = %d, want 7", got)
+	}
+}
+
+func TestRetryBudgetWindowExpires(t *testing.T) {
+	now := time.Unix(0, 0)
+	budget := &retryBudget{budget: RetryBudget{MinRetries: 1, Window: 10 * time.Second}, now: func() time.Time { return now }}
+	if !budget.withdraw() || budget.withdraw() {
+		t.Fatal("budget should allow exactly one retry")
+	}
+	now = now.Add(11 * time.Second)
+	if !budget.withdraw() {
+		t.Fatal("budget should refill after the window")
+	}
+}
+
+func TestZeroRetryBudgetUsesDefaults(t *testing.T) {
+	client := New(WithRetryBudget(RetryBudget{}))
+	if client.budget.budget.Ratio != defaultRetryBudgetRatio || client.budget.budget.MinRetries != defaultRetryBudgetMin {
+		t.Fatalf("budget = %+v", client.budget.budget)
+	}
+	if !client.budget.withdraw() {
+		t.Fatal("default budget refused the first retry")
+	}
+	client = New(WithRetryBudget(RetryBudget{Ratio: -1, MinRetries: -1}))
+	if client.budget.withdraw() {
+		t.Fatal("empty budget allowed a retry")
+	}
+}
@@ -0,0 +1,205 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const defaultMaxJSONRequestBytes int64 = 1 << 20
+
+// FieldError describes one invalid request field. Field is a dotted JSON path
+// or a multipart field name, and is empty for errors about the whole body.
+type FieldError struct {
+	Field  string `json:"field,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// ValidationError is a request that could not be decoded or failed
+// validation. Status is the reply status: 400 for malformed bodies, 413 for
+// bodies over a limit, 415 for unsupported media types, and 422 for values
+// rejected by a Validate method. Problem converts it to a problem details
+// object for WriteProblem.
+type ValidationError struct {
+	Status int
+	Detail string
+	Fields []FieldError
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	message := e.Detail
+	for _, field := range e.Fields {
+		if message != "" {
+			message += "; "
+		}
+		if field.Field != "" {
+			message += field.Field + ": "
+		}
+		message += field.Detail
+	}
+	if message == "" {
+		message = strings.ToLower(http.StatusText(e.status()))
+	}
+	return "invalid request: " + message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ValidationError) status() int {
+	if e.Status == 0 {
+		return http.StatusUnprocessableEntity
+	}
+	return e.Status
+}
+
+// Problem returns e as problem details. Fields are listed in the "errors"
+// extension member.
+func (e *ValidationError) Problem() Problem {
+	problem := Problem{
+		Title:  http.StatusText(e.status()),
+		Status: e.status(),
+		Detail: e.Detail,
+	}
+	if len(e.Fields) > 0 {
+		fields, _ := json.Marshal(e.Fields)
+		problem.Extensions = map[string]json.RawMessage{"errors": fields}
+	}
+	return problem
+}
+
+// WriteProblem replies with problem as application/problem+json. A zero
+// Status is sent as 500.
+func WriteProblem(w http.ResponseWriter, problem Problem) error {
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+	data, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// DecodeJSONOptions configures DecodeJSONRequest. MaxBytes limits the body
+// and defaults to 1 MiB. Unknown object members are rejected unless
+// AllowUnknownFields is set.
+type DecodeJSONOptions struct {
+	MaxBytes           int64
+	AllowUnknownFields bool
+}
+
+// DecodeJSONRequest decodes a request body holding exactly one JSON value
+// into T. The Content-Type must be application/json or a +json type. When T
+// or *T has a Validate() error method it is called after decoding; a
+// *ValidationError it returns is passed through and other errors become a
+// 422 ValidationError. Every failure caused by the request is a
+// *ValidationError.
+func DecodeJSONRequest[T any](w http.ResponseWriter, r *http.Request, options DecodeJSONOptions) (T, error) {
+	var value T
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return value, &ValidationError{
+			Status: http.StatusUnsupportedMediaType,
+			Detail: fmt.Sprintf("content type %q is not JSON", r.Header.Get("Content-Type")),
+			Err:    err,
+		}
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = defaultMaxJSONRequestBytes
+	}
+	body := http.MaxBytesReader(w, r.Body, options.MaxBytes)
+	defer body.Close()
+	decoder := json.NewDecoder(body)
+	if !options.AllowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(&value); err != nil {
+		return value, jsonRequestError(err)
+	}
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		if err == nil {
+			err = errors.New("request body must contain a single JSON value")
+		}
+		return value, jsonRequestError(err)
+	}
+	if err := validateValue(&value); err != nil {
+		return value, err
+	}
+	return value, nil
+}
+
+// jsonRequestError converts a decoding error into a *ValidationError.
+func jsonRequestError(err error) error {
+	var tooLarge *http.MaxBytesError
+	var syntax *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		return &ValidationError{
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit),
+			Err:    err,
+		}
+	case errors.Is(err, io.EOF):
+		return &ValidationError{Status: http.StatusBadRequest, Detail: "request body is empty", Err: err}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &ValidationError{Status: http.StatusBadRequest, Detail: "request body is truncated JSON", Err: err}
+	case errors.As(err, &syntax):
+		return &ValidationError{
+			Status: http.StatusBadRequest,
+			Detail: fmt.Sprintf("malformed JSON at offset %d", syntax.Offset),
+			Err:    err,
+		}
+	case errors.As(err, &typeErr):
+		return &ValidationError{
+			Status: http.StatusBadRequest,
+			Fields: []FieldError{{Field: typeErr.Field, Detail: "must be " + typeErr.Type.String()}},
+			Err:    err,
+		}
+	}
+	// encoding/json reports unknown members only as text.
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &ValidationError{
+			Status: http.StatusBadRequest,
+			Fields: []FieldError{{Field: strings.Trim(name, `"`), Detail: "unknown field"}},
+			Err:    err,
+		}
+	}
+	return &ValidationError{Status: http.StatusBadRequest, Detail: err.Error(), Err: err}
+}
+
+// validateValue calls the Validate method of *value or value, if any.
+func validateValue[T any](value *T) error {
+	var validator interface{ Validate() error }
+	switch typed := any(value).(type) {
+	case interface{ Validate() error }:
+		validator = typed
+	default:
+		if direct, ok := any(*value).(interface{ Validate() error }); ok {
+			validator = direct
+		}
+	}
+	if validator == nil {
+		return nil
+	}
+	err := validator.Validate()
+	if err == nil {
+		return nil
+	}
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		return err
+	}
+	return &ValidationError{Status: http.StatusUnprocessableEntity, Detail: err.Error(), Err: err}
+}
@@ -0,0 +1,120 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type signup struct {
+	Email string `json:"email"`
+	Age   int    `json:"age"`
+}
+
+func (s signup) Validate() error {
+	if !strings.Contains(s.Email, "@") {
+		return &ValidationError{Fields: []FieldError{{Field: "email", Detail: "must be an email address"}}}
+	}
+	return nil
+}
+
+func TestDecodeJSONRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		field       string
+	}{
+		{name: "valid", contentType: "application/json", body: `{"email":"a@b.c","age":3}`},
+		{name: "media type", contentType: "text/plain", body: `{}`, status: http.StatusUnsupportedMediaType},
+		{name: "unknown field", contentType: "application/json", body: `{"email":"a@b.c","admin":true}`,
+			status: http.StatusBadRequest, field: "admin"},
+		{name: "wrong type", contentType: "application/merge-patch+json", body: `{"age":"old"}`,
+			status: http.StatusBadRequest, field: "age"},
+		{name: "syntax", contentType: "application/json", body: `{"email":`, status: http.StatusBadRequest},
+		{name: "trailing", contentType: "application/json", body: `{"email":"a@b.c"} {}`, status: http.StatusBadRequest},
+		{name: "empty", contentType: "application/json", body: ``, status: http.StatusBadRequest},
+		{name: "too large", contentType: "application/json", body: `{"email":"` + strings.Repeat("a", 100) + `"}`,
+			status: http.StatusRequestEntityTooLarge},
+		{name: "invalid", contentType: "application/json", body: `{"email":"nobody"}`,
+			status: http.StatusUnprocessableEntity, field: "email"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(test.body))
+			request.Header.Set("Content-Type", test.contentType)
+			value, err := DecodeJSONRequest[signup](httptest.NewRecorder(), request, DecodeJSONOptions{MaxBytes: 64})
+			if test.status == 0 {
+				if err != nil || value.Email != "a@b.c" || value.Age != 3 {
+					t.Fatalf("value = %+v, err = %v", value, err)
+				}
+				return
+			}
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) || invalid.Problem().Status != test.status {
+				t.Fatalf("err = %#v, want status %d", err, test.status)
+			}
+			if test.field != "" && (len(invalid.Fields) != 1 || invalid.Fields[0].Field != test.field) {
+				t.Fatalf("fields = %+v, want %s", invalid.Fields, test.field)
+			}
+		})
+	}
+}
+
+func TestWriteProblemFromValidationError(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	invalid := &ValidationError{
+		Status: http.StatusBadRequest,
+		Detail: "request is invalid",
+		Fields: []FieldError{{Field: "email", Detail: "is required"}},
+	}
+	if err := WriteProblem(recorder, invalid.Problem()); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusBadRequest || recorder.Header().Get("Content-Type") != ProblemContentType {
+		t.Fatalf("reply = %d %v", recorder.Code, recorder.Header())
+	}
+	var document map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	fields, _ := document["errors"].([]any)
+	if document["title"] != "Bad Request" || document["status"] != 400.0 || document["detail"] != "request is invalid" ||
+		len(fields) != 1 {
+		t.Fatalf("problem = %s", recorder.Body.String())
+	}
+
+	// The reply decodes on the client side as the same problem.
+	status := &StatusError{StatusCode: recorder.Code, Header: recorder.Header(), Body: recorder.Body.Bytes()}
+	var problemErr *ProblemError
+	if !errors.As(DecodeProblem(status), &problemErr) || problemErr.Problem.Detail != "request is invalid" ||
+		string(problemErr.Problem.Extensions["errors"]) != `[{"field":"email","detail":"is required"}]` {
+		t.Fatalf("decoded = %+v", problemErr)
+	}
+}
+
+func TestProblemMarshalsMembersInOrder(t *testing.T) {
+	problem := Problem{
+		Type:   "https://example.com/probs/out-of-credit",
+		Title:  "Out of credit",
+		Status: http.StatusForbidden,
+		Extensions: map[string]json.RawMessage{
+			"title":   json.RawMessage(`"ignored"`),
+			"balance": json.RawMessage(`30`),
+			"account": json.RawMessage(`"12345"`),
+		},
+	}
+	data, err := json.Marshal(problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"https://example.com/probs/out-of-credit","title":"Out of credit","status":403,` +
+		`"account":"12345","balance":30}`
+	if string(data) != want {
+		t.Fatalf("problem = %s\nwant      %s", data, want)
+	}
+}
@@ -0,0 +1,79 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHMACSignerResignsEveryAttempt(t *testing.T) {
+	secret := []byte("shared")
+	var mutex sync.Mutex
+	var timestamps []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get("X-Signature-Timestamp")
+		canonical := r.Method + "\n" + r.URL.RequestURI() + "\n" + timestamp + "\n" + hexSHA256(string(data)) +
+			"\ncontent-type:" + r.Header.Get("Content-Type")
+		if want := hex.EncodeToString(hmacSHA256(secret, canonical)); r.Header.Get("X-Signature") != want {
+			t.Errorf("signature = %q, want %q", r.Header.Get("X-Signature"), want)
+		}
+		if r.Header.Get("X-Signature-Key-Id") != "key-1" {
+			t.Errorf("key id = %q", r.Header.Get("X-Signature-Key-Id"))
+		}
+		mutex.Lock()
+		timestamps = append(timestamps, timestamp)
+		attempt := len(timestamps)
+		mutex.Unlock()
+		if attempt == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	clock := time.Unix(1_700_000_000, 0)
+	signer := &HMACSigner{
+		KeyID:         "key-1",
+		Secret:        secret,
+		SignedHeaders: []string{"Content-Type"},
+		now: func() time.Time {
+			clock = clock.Add(time.Second)
+			return clock
+		},
+	}
+	client := New(
+		WithSigner(signer),
+		WithRetry(RetryPolicy{MaxAttempts: 2, Methods: []string{http.MethodPost}}),
+	)
+	response, err := client.Do(context.Background(), http.MethodPost, server.URL+"/items?b=2&a=1",
+		bytes.NewReader([]byte(`{"name":"x"}`)), http.Header{"Content-Type": {"application/json"}})
+	if err != nil || string(response.Body) != "ok" {
+		t.Fatalf("response = %+v, error = %v", response, err)
+	}
+	if len(timestamps) != 2 || timestamps[0] == timestamps[1] {
+		t.Fatalf("timestamps = %v", timestamps)
+	}
+}
+
+func TestSignerErrorStopsAttempt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request must not be sent")
+	}))
+	defer server.Close()
+
+	client := New(WithSigner(&HMACSigner{Secret: []byte("k"), Payload: PayloadPrecomputed}))
+	_, err := client.DoStream(context.Background(), http.MethodPut, server.URL, strings.NewReader("data"), nil)
+	if !errors.Is(err, ErrPayloadHashRequired) {
+		t.Fatalf("error = %v", err)
+	}
+}
@@ -0,0 +1,490 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxEventLineBytes = 64 << 10
+	defaultMaxEventBytes     = 1 << 20
+	defaultMaxReconnects     = 5
+)
+
+var (
+	ErrEventLineTooLong  = errors.New("event stream line exceeds configured limit")
+	ErrEventTooLarge     = errors.New("event stream event exceeds configured limit")
+	ErrEventStreamClosed = errors.New("event stream is closed")
+)
+
+// Event is one dispatched text/event-stream message. ID is the last event ID
+// seen on the stream, which may have been set by an earlier event.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// EventReaderOptions bounds event stream parsing. Zero values use a 64 KiB
+// line limit and a 1 MiB event limit.
+type EventReaderOptions struct {
+	MaxLineBytes  int
+	MaxEventBytes int
+}
+
+func (o EventReaderOptions) normalized() EventReaderOptions {
+	if o.MaxLineBytes <= 0 {
+		o.MaxLineBytes = defaultMaxEventLineBytes
+	}
+	if o.MaxEventBytes <= 0 {
+		o.MaxEventBytes = defaultMaxEventBytes
+	}
+	return o
+}
+
+// EventReader decodes a text/event-stream body. It is intended for one
+// consumer and does not close the underlying reader.
+type EventReader struct {
+	source      io.Reader
+	options     EventReaderOptions
+	buffer      []byte
+	start       int
+	end         int
+	skipLF      bool
+	started     bool
+	line        []byte
+	data        []byte
+	hasData     bool
+	eventType   string
+	eventRetry  time.Duration
+	lastEventID string
+	reconnect   time.Duration
+	hasRetry    bool
+	failed      error
+}
+
+func NewEventReader(source io.Reader, options EventReaderOptions) *EventReader {
+	options = options.normalized()
+	return &EventReader{
+		source:  source,
+		options: options,
+		buffer:  make([]byte, 4<<10),
+	}
+}
+
+// LastEventID returns the ID that should be sent as Last-Event-ID when
+// reconnecting.
+func (r *EventReader) LastEventID() string {
+	if r == nil {
+		return ""
+	}
+	return r.lastEventID
+}
+
+// Next returns the next complete event. It returns io.EOF when the stream ends;
+// an incomplete trailing event is discarded as the specification requires.
+func (r *EventReader) Next() (Event, error) {
+	if r == nil {
+		return Event{}, errors.New("event reader cannot be nil")
+	}
+	if r.failed != nil {
+		return Event{}, r.failed
+	}
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			r.failed = err
+			return Event{}, err
+		}
+		if len(line) == 0 {
+			if event, ok := r.dispatch(); ok {
+				return event, nil
+			}
+			continue
+		}
+		if err := r.processLine(line); err != nil {
+			r.failed = err
+			return Event{}, err
+		}
+	}
+}
+
+func (r *EventReader) dispatch() (Event, bool) {
+	retry := r.eventRetry
+	r.eventRetry = 0
+	if !r.hasData {
+		r.eventType = ""
+		return Event{}, false
+	}
+	data := r.data
+	if len(data) > 0 && data[len(data)-1] == '\n' {
+		data = data[:len(data)-1]
+	}
+	event := Event{
+		ID:    r.lastEventID,
+		Event: r.eventType,
+		Data:  string(data),
+		Retry: retry,
+	}
+	if event.Event == "" {
+		event.Event = "message"
+	}
+	r.data = r.data[:0]
+	r.hasData = false
+	r.eventType = ""
+	return event, true
+}
+
+func (r *EventReader) processLine(line []byte) error {
+	if line[0] == ':' {
+		return nil
+	}
+	field, value := line, []byte(nil)
+	if index := bytes.IndexByte(line, ':'); index >= 0 {
+		field, value = line[:index], line[index+1:]
+		if len(value) > 0 && value[0] == ' ' {
+			value = value[1:]
+		}
+	}
+	switch string(field) {
+	case "data":
+		if len(r.data)+len(value)+1 > r.options.MaxEventBytes {
+			return ErrEventTooLarge
+		}
+		r.data = append(r.data, value...)
+		r.data = append(r.data, '\n')
+		r.hasData = true
+	case "event":
+		r.eventType = string(value)
+	case "id":
+		if bytes.IndexByte(value, 0) < 0 {
+			r.lastEventID = string(value)
+		}
+	case "retry":
+		if milliseconds, ok := parseEventRetry(value); ok {
+			r.eventRetry = time.Duration(milliseconds) * time.Millisecond
+			r.reconnect = r.eventRetry
+			r.hasRetry = true
+		}
+	}
+	return nil
+}
+
+func parseEventRetry(value []byte) (int64, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return 0, false
+		}
+	}
+	milliseconds, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil || milliseconds > int64(time.Duration(1<<63-1)/time.Millisecond) {
+		return 0, false
+	}
+	return milliseconds, true
+}
+
+// readLine returns one line without its terminator. CR, LF, and CRLF are all
+// accepted; a CR is never held back waiting to see whether LF follows. A UTF-8
+// byte order mark at the start of the stream is removed.
+func (r *EventReader) readLine() ([]byte, error) {
+	r.line = r.line[:0]
+	for {
+		if r.start == r.end {
+			count, err := r.source.Read(r.buffer)
+			if count == 0 {
+				if err == nil {
+					continue
+				}
+				if errors.Is(err, io.EOF) {
+					return nil, io.EOF
+				}
+				return nil, err
+			}
+			r.start, r.end = 0, count
+		}
+		if r.skipLF {
+			r.skipLF = false
+			if r.buffer[r.start] == '\n' {
+				r.start++
+				continue
+			}
+		}
+		chunk := r.buffer[r.start:r.end]
+		index := bytes.IndexAny(chunk, "\r\n")
+		if index < 0 {
+			if len(r.line)+len(chunk) > r.options.MaxLineBytes {
+				return nil, ErrEventLineTooLong
+			}
+			r.line = append(r.line, chunk...)
+			r.start = r.end
+			continue
+		}
+		if len(r.line)+index > r.options.MaxLineBytes {
+			return nil, ErrEventLineTooLong
+		}
+		r.line = append(r.line, chunk[:index]...)
+		r.skipLF = chunk[index] == '\r'
+		r.start += index + 1
+		if !r.started {
+			r.started = true
+			r.line = bytes.TrimPrefix(r.line, []byte("\xEF\xBB\xBF"))
+		}
+		return r.line, nil
+	}
+}
+
+// EventStreamOptions configures Client.OpenEventStream. When Reconnect is set,
+// a dropped stream is reopened with Last-Event-ID after the server-provided
+// retry delay or, if none was sent, the client's RetryPolicy backoff.
+// MaxReconnects bounds consecutive reconnects that deliver no event; zero
+// uses 5. Reconnects draw from the client's RetryBudget, if any.
+type EventStreamOptions struct {
+	EventReaderOptions
+	Reconnect     bool
+	MaxReconnects int
+	MaxErrorBytes int64
+	OnReconnect   func(RetryEvent)
+}
+
+// EventStream reads events from a text/event-stream endpoint and optionally
+// reconnects when the connection drops. Next is intended for one consumer;
+// Close may be called concurrently to interrupt it.
+type EventStream struct {
+	readMutex  sync.Mutex
+	stateMutex sync.Mutex
+	ctx        context.Context
+	cancel     context.CancelFunc
+	client     *Client
+	request    Request
+	options    EventStreamOptions
+	response   *StreamResponse
+	reader     *EventReader
+	lastID     string
+	reconnect  time.Duration
+	hasRetry   bool
+	failures   int
+	backoff    time.Duration
+	closed     bool
+}
+
+// OpenEventStream sends request and returns a stream of its events. The
+// request body, if any, must be replayable when reconnecting is enabled. A 204
+// response yields a stream that is already at io.EOF.
+func (c *Client) OpenEventStream(
+	ctx context.Context,
+	request Request,
+	options EventStreamOptions,
+) (*EventStream, error) {
+	if c == nil {
+		c = New()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	options.EventReaderOptions = options.EventReaderOptions.normalized()
+	if options.MaxReconnects <= 0 {
+		options.MaxReconnects = defaultMaxReconnects
+	}
+	request.Header = request.Header.Clone()
+	if request.Header == nil {
+		request.Header = make(http.Header)
+	}
+	request.Header.Set("Accept", "text/event-stream")
+	request.Header.Set("Cache-Control", "no-cache")
+
+	ctx, cancel := context.WithCancel(ctx)
+	stream := &EventStream{
+		ctx:     ctx,
+		cancel:  cancel,
+		client:  c,
+		request: request,
+		options: options,
+		lastID:  request.Header.Get("Last-Event-ID"),
+	}
+	if err := stream.connect(); err != nil && !errors.Is(err, io.EOF) {
+		cancel()
+		return nil, err
+	}
+	return stream, nil
+}
+
+// Response returns the response of the current connection, or nil between
+// connections.
+func (s *EventStream) Response() *StreamResponse {
+	if s == nil {
+		return nil
+	}
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	return s.response
+}
+
+// LastEventID returns the most recent event ID received on any connection.
+func (s *EventStream) LastEventID() string {
+	if s == nil {
+		return ""
+	}
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	return s.lastID
+}
+
+// Next returns the next event. It returns io.EOF when the stream ends and
+// reconnecting is disabled, or when the server answers with 204 No Content.
+func (s *EventStream) Next() (Event, error) {
+	if s == nil {
+		return Event{}, errors.New("event stream cannot be nil")
+	}
+	s.readMutex.Lock()
+	defer s.readMutex.Unlock()
+	for {
+		s.stateMutex.Lock()
+		closed, reader := s.closed, s.reader
+		s.stateMutex.Unlock()
+		if closed {
+			return Event{}, ErrEventStreamClosed
+		}
+		if reader == nil {
+			return Event{}, io.EOF
+		}
+
+		event, err := reader.Next()
+		s.stateMutex.Lock()
+		if s.closed {
+			s.stateMutex.Unlock()
+			return Event{}, ErrEventStreamClosed
+		}
+		s.lastID = reader.LastEventID()
+		if reader.hasRetry {
+			s.reconnect, s.hasRetry = reader.reconnect, true
+		}
+		if err == nil {
+			s.failures = 0
+			s.backoff = 0
+			s.stateMutex.Unlock()
+			return event, nil
+		}
+		if errors.Is(err, ErrEventLineTooLong) || errors.Is(err, ErrEventTooLarge) {
+			s.stateMutex.Unlock()
+			return Event{}, err
+		}
+		_ = s.response.Close()
+		s.response = nil
+		s.reader = nil
+		s.stateMutex.Unlock()
+
+		if ctxErr := s.ctx.Err(); ctxErr != nil {
+			return Event{}, ctxErr
+		}
+		if !s.options.Reconnect {
+			return Event{}, err
+		}
+		if err := s.reconnectAfter(err); err != nil {
+			return Event{}, err
+		}
+	}
+}
+
+// Close closes the current connection and stops reconnecting.
+func (s *EventStream) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.cancel()
+	if s.response == nil {
+		return nil
+	}
+	err := s.response.Close()
+	s.response = nil
+	s.reader = nil
+	return err
+}
+
+func (s *EventStream) reconnectAfter(cause error) error {
+	policy := s.client.retry()
+	for {
+		s.failures++
+		if s.failures > s.options.MaxReconnects ||
+			(s.client.budget != nil && !s.client.budget.withdraw()) {
+			return cause
+		}
+		s.stateMutex.Lock()
+		delay, hasRetry, lastID := s.reconnect, s.hasRetry, s.lastID
+		s.stateMutex.Unlock()
+		if !hasRetry {
+			delay = policy.delay(s.failures, s.backoff, nil)
+			s.backoff = delay
+		}
+		if s.options.OnReconnect != nil {
+			s.options.OnReconnect(RetryEvent{
+				Attempt:     s.failures,
+				NextAttempt: s.failures + 1,
+				Method:      s.request.Method,
+				URL:         s.request.URL,
+				Err:         cause,
+				Delay:       delay,
+			})
+		}
+		if err := waitForRetry(s.ctx, delay); err != nil {
+			return err
+		}
+		if lastID != "" {
+			s.request.Header.Set("Last-Event-ID", lastID)
+		}
+		err := s.connect()
+		if err == nil || errors.Is(err, io.EOF) {
+			return err
+		}
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) || errors.Is(err, ErrUnexpectedContentType) || s.ctx.Err() != nil {
+			return err
+		}
+		cause = err
+	}
+}
+
+func (s *EventStream) connect() error {
+	response, err := s.client.DoRequest(s.ctx, s.request)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusNoContent {
+		_ = response.Close()
+		return io.EOF
+	}
+	if err := response.CheckStatus(s.options.MaxErrorBytes); err != nil {
+		return err
+	}
+	if err := response.RequireContentType("text/event-stream"); err != nil {
+		_ = response.Close()
+		return err
+	}
+
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	if s.closed {
+		_ = response.Close()
+		return ErrEventStreamClosed
+	}
+	reader := NewEventReader(response.Body, s.options.EventReaderOptions)
+	reader.lastEventID = s.lastID
+	s.response = response
+	s.reader = reader
+	return nil
+}
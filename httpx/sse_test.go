@@ -0,0 +1,196 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventReaderParsesFields(t *testing.T) {
+	input := ": comment\r\n" +
+		"id: 1\r\n" +
+		"event: update\r\n" +
+		"data: first\r\n" +
+		"data:second\r\n" +
+		"retry: 250\r\n" +
+		"\r\n" +
+		"data: third\r\r" +
+		"id\n" +
+		"data\n\n" +
+		"data: incomplete"
+	reader := NewEventReader(strings.NewReader(input), EventReaderOptions{})
+
+	event, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != "1" || event.Event != "update" || event.Data != "first\nsecond" || event.Retry != 250*time.Millisecond {
+		t.Fatalf("first event = %+v", event)
+	}
+	event, err = reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != "1" || event.Event != "message" || event.Data != "third" || event.Retry != 0 {
+		t.Fatalf("second event = %+v", event)
+	}
+	event, err = reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != "" || event.Data != "" {
+		t.Fatalf("third event = %+v", event)
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("trailing event error = %v", err)
+	}
+}
+
+func TestEventReaderStripsByteOrderMark(t *testing.T) {
+	reader := NewEventReader(strings.NewReader("\xEF\xBB\xBFdata: first\n\ndata: \xEF\xBB\xBFsecond\n\n"), EventReaderOptions{})
+	event, err := reader.Next()
+	if err != nil || event.Data != "first" {
+		t.Fatalf("first event = %+v, error = %v", event, err)
+	}
+	event, err = reader.Next()
+	if err != nil || event.Data != "\xEF\xBB\xBFsecond" {
+		t.Fatalf("second event = %+v, error = %v", event, err)
+	}
+}
+
+func TestEventReaderLimits(t *testing.T) {
+	reader := NewEventReader(strings.NewReader("data: 123456789\n\n"), EventReaderOptions{MaxLineBytes: 8})
+	if _, err := reader.Next(); !errors.Is(err, ErrEventLineTooLong) {
+		t.Fatalf("line error = %v", err)
+	}
+	reader = NewEventReader(strings.NewReader("data: 1234\ndata: 5678\n\n"), EventReaderOptions{MaxEventBytes: 8})
+	if _, err := reader.Next(); !errors.Is(err, ErrEventTooLarge) {
+		t.Fatalf("event error = %v", err)
+	}
+}
+
+func TestEventStreamReconnectsWithLastEventID(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("accept = %q", r.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch connections.Add(1) {
+		case 1:
+			_, _ = io.WriteString(w, "retry: 1\nid: 7\ndata: first\n\n")
+		case 2:
+			if r.Header.Get("Last-Event-ID") != "7" {
+				t.Errorf("last event id = %q", r.Header.Get("Last-Event-ID"))
+			}
+			_, _ = io.WriteString(w, "id: 8\ndata: second\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	var reconnects atomic.Int32
+	stream, err := New().OpenEventStream(context.Background(), Request{URL: server.URL}, EventStreamOptions{
+		Reconnect: true,
+		OnReconnect: func(event RetryEvent) {
+			if event.Delay != time.Millisecond {
+				t.Errorf("reconnect delay = %v", event.Delay)
+			}
+			reconnects.Add(1)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	var data []string
+	for {
+		event, err := stream.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, event.Data)
+	}
+	if strings.Join(data, ",") != "first,second" || stream.LastEventID() != "8" || reconnects.Load() != 2 {
+		t.Fatalf("data = %q, last id = %q, reconnects = %d", data, stream.LastEventID(), reconnects.Load())
+	}
+}
+
+func TestEventStreamHonoursZeroRetry(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if connections.Add(1) > 1 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "retry: 0\ndata: only\n\n")
+	}))
+	defer server.Close()
+
+	client := New(WithRetry(RetryPolicy{BaseDelay: time.Hour}))
+	stream, err := client.OpenEventStream(context.Background(), Request{URL: server.URL}, EventStreamOptions{
+		Reconnect: true,
+		OnReconnect: func(event RetryEvent) {
+			if event.Delay != 0 {
+				t.Errorf("reconnect delay = %v", event.Delay)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if event, err := stream.Next(); err != nil || event.Data != "only" {
+		t.Fatalf("event = %+v, error = %v", event, err)
+	}
+	if _, err := stream.Next(); !errors.Is(err, io.EOF) || connections.Load() != 2 {
+		t.Fatalf("error = %v, connections = %d", err, connections.Load())
+	}
+}
+
+func TestEventStreamReconnectsByDefault(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		connections.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "retry: 1\n\n")
+	}))
+	defer server.Close()
+
+	stream, err := New().OpenEventStream(context.Background(), Request{URL: server.URL}, EventStreamOptions{Reconnect: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if _, err := stream.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("error = %v", err)
+	}
+	if got := connections.Load(); got != 1+defaultMaxReconnects {
+		t.Fatalf("connections = %d, want %d", got, 1+defaultMaxReconnects)
+	}
+}
+
+func TestEventStreamRejectsWrongContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, "{}")
+	}))
+	defer server.Close()
+
+	_, err := New().OpenEventStream(context.Background(), Request{URL: server.URL}, EventStreamOptions{})
+	if !errors.Is(err, ErrUnexpectedContentType) {
+		t.Fatalf("error = %v", err)
+	}
+}
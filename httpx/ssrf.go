@@ -0,0 +1,278 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBlockedAddress       = errors.New("destination address is not allowed")
+	ErrUnsupportedTransport = errors.New("ssrf protection requires an *http.Transport")
+)
+
+// blockedPrefixes are special-purpose ranges that net/netip does not classify
+// but that reach internal or non-public networks.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	// 6to4 and Teredo addresses embed IPv4 addresses that may be private.
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("2001::/32"),
+}
+
+// BlockedAddressError reports a connection refused by an SSRFGuard.
+type BlockedAddressError struct {
+	Host string
+	IP   netip.Addr
+}
+
+func (e *BlockedAddressError) Error() string {
+	return fmt.Sprintf("%v: %s resolves to %s", ErrBlockedAddress, e.Host, e.IP)
+}
+
+func (e *BlockedAddressError) Unwrap() error {
+	return ErrBlockedAddress
+}
+
+// SSRFPolicy configures an SSRFGuard. AllowPrefixes and AllowHosts are
+// explicit exceptions: addresses inside an allowed prefix, and any address of
+// an allowed host name, may be dialed even if they are private. Resolver
+// defaults to net.DefaultResolver.
+type SSRFPolicy struct {
+	AllowPrefixes []netip.Prefix
+	AllowHosts    []string
+	Resolver      *net.Resolver
+}
+
+// SSRFGuard refuses connections to loopback, private, link-local, multicast,
+// unspecified, and other special-purpose addresses, which include cloud
+// metadata endpoints such as 169.254.169.254, and 6to4 and Teredo addresses,
+// which can embed private IPv4 addresses. Host names are resolved once and the
+// connection is made to the checked address, so a DNS answer cannot change
+// between the check and the dial. A custom Transport.DialTLSContext is given
+// the host name instead, for SNI and certificate verification, and the
+// address it connects to is checked afterwards. A host with any blocked
+// address is refused.
+type SSRFGuard struct {
+	policy SSRFPolicy
+	hosts  map[string]struct{}
+}
+
+func NewSSRFGuard(policy SSRFPolicy) *SSRFGuard {
+	guard := &SSRFGuard{policy: policy, hosts: make(map[string]struct{})}
+	for _, host := range policy.AllowHosts {
+		host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+		if host != "" {
+			guard.hosts[host] = struct{}{}
+		}
+	}
+	if guard.policy.Resolver == nil {
+		guard.policy.Resolver = net.DefaultResolver
+	}
+	return guard
+}
+
+// WithSSRFProtection guards every connection the client opens, including
+// connections for redirect targets. The guard is installed on a clone of the
+// client's *http.Transport when New returns, so option order does not
+// matter. Proxies are disabled because a proxy would resolve and dial the
+// destination itself. Requests fail with ErrUnsupportedTransport when the
+// client uses another RoundTripper; such transports can call
+// SSRFGuard.DialContext themselves.
+func WithSSRFProtection(policy SSRFPolicy) Option {
+	return func(client *Client) {
+		client.ssrf = NewSSRFGuard(policy)
+	}
+}
+
+// Allowed reports whether ip may be dialed for host.
+func (g *SSRFGuard) Allowed(host string, ip netip.Addr) bool {
+	if _, ok := g.hosts[strings.ToLower(strings.TrimSuffix(host, "."))]; ok {
+		return true
+	}
+	ip = ip.Unmap()
+	for _, prefix := range g.policy.AllowPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return !blockedAddress(ip)
+}
+
+// DialContext resolves and checks address, then dials it with a default
+// net.Dialer.
+func (g *SSRFGuard) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return g.dialWith(dialer.DialContext)(ctx, network, address)
+}
+
+// resolve returns the checked addresses of host.
+func (g *SSRFGuard) resolve(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	var addresses []netip.Addr
+	if ip, err := netip.ParseAddr(host); err == nil {
+		addresses = []netip.Addr{ip}
+	} else {
+		addresses, err = g.policy.Resolver.LookupNetIP(ctx, resolverNetwork(network), host)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	for _, ip := range addresses {
+		if !g.Allowed(host, ip) {
+			return nil, &BlockedAddressError{Host: host, IP: ip.Unmap()}
+		}
+	}
+	return addresses, nil
+}
+
+// dialWith returns a dial function that checks every resolved address and
+// passes IP literals to dial.
+func (g *SSRFGuard) dialWith(
+	dial func(context.Context, string, string) (net.Conn, error),
+) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		addresses, err := g.resolve(ctx, network, host)
+		if err != nil {
+			return nil, err
+		}
+		var dialErr error
+		for _, ip := range addresses {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip.Unmap().String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			dialErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, dialErr
+	}
+}
+
+// dialHostWith returns a dial function for dialers that need the host name,
+// such as TLS dialers that use it for SNI and certificate verification. It
+// checks the resolved addresses, passes the original address to dial, and
+// then checks the address actually connected to, so a DNS answer that changes
+// in between is still refused.
+func (g *SSRFGuard) dialHostWith(
+	dial func(context.Context, string, string) (net.Conn, error),
+) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := g.resolve(ctx, network, host); err != nil {
+			return nil, err
+		}
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		remote, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+		if err != nil || !g.Allowed(host, remote.Addr()) {
+			_ = conn.Close()
+			return nil, &BlockedAddressError{Host: host, IP: remote.Addr().Unmap()}
+		}
+		return conn, nil
+	}
+}
+
+// client returns a copy of httpClient whose transport dials through the guard.
+func (g *SSRFGuard) client(httpClient *http.Client) *http.Client {
+	guarded := *httpClient
+	var transport *http.Transport
+	switch base := httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = base.Clone()
+	default:
+		guarded.Transport = unsupportedTransport{}
+		return &guarded
+	}
+	transport.Proxy = nil
+	dial := transport.DialContext
+	if dial == nil && transport.Dial != nil {
+		dial = withoutContext(transport.Dial)
+	}
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	transport.DialContext = g.dialWith(dial)
+	dialTLS := transport.DialTLSContext
+	if dialTLS == nil && transport.DialTLS != nil {
+		dialTLS = withoutContext(transport.DialTLS)
+	}
+	if dialTLS != nil {
+		transport.DialTLSContext = g.dialHostWith(dialTLS)
+	}
+	// The deprecated fields are only used when the context variants are nil,
+	// but clear them so the guard cannot be bypassed through them.
+	transport.Dial = nil
+	transport.DialTLS = nil
+	guarded.Transport = transport
+	return &guarded
+}
+
+// withoutContext adapts a dial function from the deprecated Transport.Dial
+// and Transport.DialTLS fields.
+func withoutContext(
+	dial func(string, string) (net.Conn, error),
+) func(context.Context, string, string) (net.Conn, error) {
+	return func(_ context.Context, network, address string) (net.Conn, error) {
+		return dial(network, address)
+	}
+}
+
+func blockedAddress(ip netip.Addr) bool {
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func resolverNetwork(network string) string {
+	switch network {
+	case "tcp4", "udp4":
+		return "ip4"
+	case "tcp6", "udp6":
+		return "ip6"
+	}
+	return "ip"
+}
+
+type unsupportedTransport struct{}
+
+func (unsupportedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Body != nil {
+		_ = request.Body.Close()
+	}
+	return nil, ErrUnsupportedTransport
+}
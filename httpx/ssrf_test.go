@@ -0,0 +1,169 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSSRFGuardBlocksInternalAddresses(t *testing.T) {
+	guard := NewSSRFGuard(SSRFPolicy{
+		AllowPrefixes: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+		AllowHosts:    []string{"Internal.Example."},
+	})
+	tests := []struct {
+		host    string
+		ip      string
+		allowed bool
+	}{
+		{"example.com", "93.184.216.34", true},
+		{"example.com", "127.0.0.1", false},
+		{"example.com", "::1", false},
+		{"example.com", "::ffff:127.0.0.1", false},
+		{"example.com", "169.254.169.254", false},
+		{"example.com", "fd00:ec2::254", false},
+		{"example.com", "100.100.100.200", false},
+		{"example.com", "192.168.1.10", false},
+		{"example.com", "0.0.0.0", false},
+		{"example.com", "10.2.0.1", false},
+		{"example.com", "10.1.0.1", true},
+		{"example.com", "2002:c0a8:10a::1", false},
+		{"example.com", "2001:0:4136:e378:8000:63bf:3fff:fdd2", false},
+		{"example.com", "2606:4700::1111", true},
+		{"internal.example", "192.168.1.10", true},
+	}
+	for _, test := range tests {
+		if got := guard.Allowed(test.host, netip.MustParseAddr(test.ip)); got != test.allowed {
+			t.Errorf("Allowed(%q, %s) = %v, want %v", test.host, test.ip, got, test.allowed)
+		}
+	}
+}
+
+func TestSSRFProtectionAppliesToEveryConnection(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, strings.Replace(r.Header.Get("X-Target"), "127.0.0.1", "localhost", 1), http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	blocked := New(
+		WithSSRFProtection(SSRFPolicy{}),
+		WithRetry(RetryPolicy{MaxAttempts: 3, RetryTransportErrors: true}),
+	)
+	_, err := blocked.Do(context.Background(), http.MethodGet, server.URL, nil, nil)
+	var blockedErr *BlockedAddressError
+	if !errors.As(err, &blockedErr) || !errors.Is(err, ErrBlockedAddress) || hits.Load() != 0 {
+		t.Fatalf("error = %v, hits = %d", err, hits.Load())
+	}
+
+	client := New(WithSSRFProtection(SSRFPolicy{AllowHosts: []string{"127.0.0.1"}}))
+	response, err := client.Do(context.Background(), http.MethodGet, server.URL+"/ok", nil, nil)
+	if err != nil || response.StatusCode != http.StatusNoContent {
+		t.Fatalf("response = %+v, error = %v", response, err)
+	}
+	_, err = client.Do(context.Background(), http.MethodGet, server.URL+"/redirect", nil,
+		http.Header{"X-Target": {server.URL + "/ok"}})
+	if !errors.Is(err, ErrBlockedAddress) || hits.Load() != 2 {
+		t.Fatalf("redirect error = %v, hits = %d", err, hits.Load())
+	}
+}
+
+func TestSSRFProtectionPassesHostToTLSDialer(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var dialed atomic.Value
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.DialTLSContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed.Store(address)
+		// The test certificate is issued for example.com, not localhost.
+		config := transport.TLSClientConfig.Clone()
+		config.ServerName = "example.com"
+		return (&tls.Dialer{Config: config}).DialContext(ctx, network, address)
+	}
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	client := New(
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithSSRFProtection(SSRFPolicy{AllowPrefixes: []netip.Prefix{
+			netip.MustParsePrefix("127.0.0.0/8"),
+			netip.MustParsePrefix("::1/128"),
+		}}),
+	)
+	response, err := client.Do(context.Background(), http.MethodGet, url, nil, nil)
+	if err != nil || response.StatusCode != http.StatusNoContent {
+		t.Fatalf("response = %+v, error = %v", response, err)
+	}
+	if address, _ := dialed.Load().(string); !strings.HasPrefix(address, "localhost:") {
+		t.Fatalf("TLS dialer got %q, want the host name", address)
+	}
+
+	blocked := New(WithHTTPClient(&http.Client{Transport: transport}), WithSSRFProtection(SSRFPolicy{}))
+	if _, err := blocked.Do(context.Background(), http.MethodGet, url, nil, nil); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("blocked error = %v", err)
+	}
+}
+
+func TestSSRFProtectionWrapsDeprecatedDialers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var dials, tlsDials atomic.Int32
+	transport := &http.Transport{
+		Dial: func(network, address string) (net.Conn, error) {
+			dials.Add(1)
+			return net.Dial(network, address)
+		},
+		DialTLS: func(network, address string) (net.Conn, error) {
+			tlsDials.Add(1)
+			return tls.Dial(network, address, &tls.Config{InsecureSkipVerify: true})
+		},
+	}
+	allowed := New(
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithSSRFProtection(SSRFPolicy{AllowHosts: []string{"127.0.0.1"}}),
+	)
+	response, err := allowed.Do(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil || response.StatusCode != http.StatusNoContent || dials.Load() != 1 {
+		t.Fatalf("response = %+v, error = %v, dials = %d", response, err, dials.Load())
+	}
+
+	blocked := New(WithHTTPClient(&http.Client{Transport: transport}), WithSSRFProtection(SSRFPolicy{}))
+	for _, url := range []string{server.URL, strings.Replace(server.URL, "http:", "https:", 1)} {
+		if _, err := blocked.Do(context.Background(), http.MethodGet, url, nil, nil); !errors.Is(err, ErrBlockedAddress) {
+			t.Fatalf("%s error = %v", url, err)
+		}
+	}
+	if dials.Load() != 1 || tlsDials.Load() != 0 {
+		t.Fatalf("dials = %d, TLS dials = %d after blocked requests", dials.Load(), tlsDials.Load())
+	}
+}
+
+func TestSSRFProtectionRejectsUnknownTransport(t *testing.T) {
+	client := New(
+		WithHTTPClient(&http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			t.Error("transport must not be called")
+			return nil, errors.New("unexpected")
+		})}),
+		WithSSRFProtection(SSRFPolicy{}),
+	)
+	_, err := client.Do(context.Background(), http.MethodGet, "http://example.com", nil, nil)
+	if !errors.Is(err, ErrUnsupportedTransport) {
+		t.Fatalf("error = %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"mime"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -30,12 +31,14 @@ type Request struct {
 }
 
 // StreamResponse exposes the response body without buffering it. The caller
-// owns Body and must close it.
+// owns Body and must close it. Redirects lists the redirects followed to reach
+// the response, oldest first.
 type StreamResponse struct {
 	StatusCode    int
 	Header        http.Header
 	Body          io.ReadCloser
 	ContentLength int64
+	Redirects     []Redirect
 }
 
 type ContentTypeError struct {
@@ -144,22 +147,25 @@ func contentTypeMatches(actual, allowed string) bool {
 	return false
 }
 
+// limitedReadCloser fails with tooLarge, or ErrBodyTooLarge when it is nil,
+// once more than remaining bytes are available.
 type limitedReadCloser struct {
 	body      io.ReadCloser
 	remaining int64
 	exceeded  bool
+	tooLarge  error
 }
 
 func (r *limitedReadCloser) Read(target []byte) (int, error) {
 	if r.exceeded {
-		return 0, ErrBodyTooLarge
+		return 0, r.limitError()
 	}
 	if r.remaining == 0 {
 		var extra [1]byte
 		count, err := r.body.Read(extra[:])
 		if count > 0 {
 			r.exceeded = true
-			return 0, ErrBodyTooLarge
+			return 0, r.limitError()
 		}
 		return 0, err
 	}
@@ -175,6 +181,13 @@ func (r *limitedReadCloser) Close() error {
 	return r.body.Close()
 }
 
+func (r *limitedReadCloser) limitError() error {
+	if r.tooLarge != nil {
+		return r.tooLarge
+	}
+	return ErrBodyTooLarge
+}
+
 // DoStream sends one streaming request. When retries are configured, body
 // must be one of the replayable reader types recognized by net/http.
 func (c *Client) DoStream(
@@ -209,7 +222,8 @@ func (c *Client) DoStream(
 	}
 
 	policy := c.retry()
-	if body != nil && policy.MaxAttempts > 1 && policy.allowsMethod(probe.Method) && probe.GetBody == nil {
+	retried := policy.allowsMethod(probe.Method) || (c.idempotency != nil && c.idempotency.allowsMethod(probe.Method))
+	if body != nil && policy.MaxAttempts > 1 && retried && probe.GetBody == nil {
 		_ = probe.Body.Close()
 		return nil, ErrBodyNotReplayable
 	}
@@ -234,19 +248,49 @@ func (c *Client) DoRequest(ctx context.Context, request Request) (*StreamRespons
 	if request.Method == "" {
 		request.Method = http.MethodGet
 	}
+	return c.observe(ctx, request, c.dispatch)
+}
+
+// dispatch sends request through the cache when it applies and through the
+// retry loop otherwise.
+func (c *Client) dispatch(ctx context.Context, request Request) (*StreamResponse, error) {
+	if c.cache != nil && request.Method == http.MethodGet && request.Body == nil {
+		return c.doCached(ctx, request)
+	}
+	return c.doWithRetry(ctx, request)
+}
 
+// doWithRetry sends request through the retry loop.
+func (c *Client) doWithRetry(ctx context.Context, request Request) (*StreamResponse, error) {
+	request, keyed, err := c.idempotencyKey(request)
+	if err != nil {
+		return nil, err
+	}
 	policy := c.retry()
 	attempts := 1
-	if policy.allowsMethod(request.Method) {
+	if keyed || policy.allowsMethod(request.Method) {
 		attempts = policy.MaxAttempts
 	}
 
+	if c.budget != nil {
+		c.budget.request()
+	}
+	hedged := c.hedge != nil && c.hedge.allowsMethod(request.Method)
+	var delay time.Duration
 	for attempt := 1; attempt <= attempts; attempt++ {
-		response, err := c.doAttempt(ctx, request)
-		shouldRetry := attempt < attempts &&
-			((err != nil && policy.RetryTransportErrors) ||
-				(err == nil && response != nil && policy.allowsStatus(response.StatusCode)))
-		if !shouldRetry || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		var response *StreamResponse
+		var err error
+		if hedged && attempt < max(attempts, 2) {
+			response, attempt, err = c.hedgedAttempt(ctx, request, attempt, policy)
+		} else {
+			response, err = c.runAttempt(ctx, request, attempt)
+		}
+		outcome := RetryOutcome{Attempt: attempt, Method: request.Method, URL: request.URL, Err: err}
+		if err == nil && response != nil {
+			outcome.StatusCode, outcome.Header = response.StatusCode, response.Header
+		}
+		if attempt >= attempts || permanentError(err) || !policy.retryable(outcome) ||
+			(c.budget != nil && !c.budget.withdraw()) {
 			return response, err
 		}
 
@@ -258,7 +302,7 @@ func (c *Client) DoRequest(ctx context.Context, request Request) (*StreamRespons
 			}
 			drainAndClose(response.Body)
 		}
-		delay := policy.delay(attempt, rawResponse)
+		delay = policy.delay(attempt, delay, rawResponse)
 		if policy.OnRetry != nil {
 			event := RetryEvent{
 				Attempt:     attempt,
@@ -280,21 +324,119 @@ func (c *Client) DoRequest(ctx context.Context, request Request) (*StreamRespons
 	return nil, errors.New("http retry loop ended unexpectedly")
 }
 
+// permanentError reports errors that another attempt cannot fix.
+func permanentError(err error) bool {
+	var redirectErr *RedirectError
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrBlockedAddress) || errors.As(err, &redirectErr)
+}
+
+// runAttempt applies client-wide admission checks around one attempt.
+func (c *Client) runAttempt(ctx context.Context, request Request, attempt int) (*StreamResponse, error) {
+	var record func(int, error)
+	if c.breaker != nil {
+		var err error
+		record, err = c.breaker.allow(requestHost(request.URL))
+		if err != nil {
+			return nil, err
+		}
+	}
+	var host *hostLimiter
+	release := func() {}
+	if c.limiter != nil {
+		var err error
+		host, release, err = c.limiter.acquire(ctx, request.URL)
+		if err != nil {
+			if record != nil {
+				record(0, err)
+			}
+			return nil, err
+		}
+	}
+
+	ctx = withAttempt(ctx, attempt)
+	send := c.doAttempt
+	if c.tokens != nil {
+		send = c.authorizedAttempt
+	}
+	response, err := c.timedAttempt(ctx, request, send)
+	if record != nil {
+		statusCode := 0
+		if response != nil {
+			statusCode = response.StatusCode
+		}
+		record(statusCode, err)
+	}
+	if response == nil || response.Body == nil {
+		release()
+		return response, err
+	}
+	if c.limiter != nil {
+		c.limiter.observe(host, response.StatusCode, response.Header)
+		response.Body = &releaseOnClose{body: response.Body, release: release}
+	}
+	return response, err
+}
+
+// timedAttempt runs send under RetryPolicy.AttemptTimeout. The timer stops
+// once response headers arrive, and the attempt context ends when the body is
+// closed.
+func (c *Client) timedAttempt(
+	ctx context.Context,
+	request Request,
+	send func(context.Context, Request) (*StreamResponse, error),
+) (*StreamResponse, error) {
+	timeout := c.retryPolicy.AttemptTimeout
+	if timeout <= 0 {
+		return send(ctx, request)
+	}
+	attemptCtx, cancel := context.WithCancelCause(ctx)
+	timer := time.AfterFunc(timeout, func() { cancel(ErrAttemptTimeout) })
+	response, err := send(attemptCtx, request)
+	if !timer.Stop() && ctx.Err() == nil {
+		if response != nil && response.Body != nil {
+			_ = response.Body.Close()
+		}
+		cancel(nil)
+		return nil, fmt.Errorf("%w after %v", ErrAttemptTimeout, timeout)
+	}
+	if response == nil || response.Body == nil {
+		cancel(nil)
+		return response, err
+	}
+	response.Body = &releaseOnClose{body: response.Body, release: func() { cancel(nil) }}
+	return response, err
+}
+
 func (c *Client) doAttempt(ctx context.Context, spec Request) (*StreamResponse, error) {
 	var body io.ReadCloser
 	var err error
+	progress := progressFromContext(ctx)
 	if spec.Body != nil {
 		body, err = spec.Body()
 		if err != nil {
 			return nil, err
 		}
+		if c.compression != nil {
+			if spec, body, err = c.compressRequest(spec, body); err != nil {
+				return nil, err
+			}
+		}
+		if progress != nil {
+			body = trackProgress(ctx, body, Upload, spec.ContentLength, progress.Interval, progress.OnUpload)
+		}
 	}
 
+	if len(c.signers) > 0 && spec.Body != nil {
+		ctx = context.WithValue(ctx, bodyFactoryContextKey{}, spec.Body)
+	}
+	ctx, reportAttempt := c.traceAttempt(ctx, spec)
 	request, err := http.NewRequestWithContext(ctx, spec.Method, spec.URL, body)
 	if err != nil {
 		if body != nil {
 			_ = body.Close()
 		}
+		reportAttempt(nil, err)
 		return nil, err
 	}
 	if body != nil && spec.ContentLength >= 0 {
@@ -302,32 +444,48 @@ func (c *Client) doAttempt(ctx context.Context, spec Request) (*StreamResponse,
 	}
 	request.Header = c.headers.Clone()
 	mergeHeaders(request.Header, spec.Header)
+	if c.decompress && request.Header.Get("Accept-Encoding") == "" {
+		request.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
 
-	for _, validator := range c.requestValidators {
-		if err := validator(request); err != nil {
+	for _, mutator := range c.requestMutators {
+		if err := mutator(request); err != nil {
 			if request.Body != nil {
 				_ = request.Body.Close()
 			}
+			reportAttempt(nil, err)
 			return nil, err
 		}
 	}
+	if err := c.validate(request); err != nil {
+		reportAttempt(nil, err)
+		return nil, err
+	}
 
-	httpClient := c.httpClient
-	if httpClient == nil {
-		httpClient = &http.Client{Timeout: 30 * time.Second}
+	response, err := c.intercept(request)
+	reportAttempt(response, err)
+	for _, observer := range c.responseObservers {
+		observer(request, response, err)
 	}
-	response, err := httpClient.Do(request)
 	if err != nil {
 		if response != nil && response.Body != nil {
 			_ = response.Body.Close()
 		}
 		return nil, err
 	}
+	if c.decompress {
+		decompressResponse(response)
+	}
+	if progress != nil {
+		response.Body = trackProgress(ctx, response.Body, Download, response.ContentLength,
+			progress.Interval, progress.OnDownload)
+	}
 	return &StreamResponse{
 		StatusCode:    response.StatusCode,
 		Header:        response.Header.Clone(),
 		Body:          response.Body,
 		ContentLength: response.ContentLength,
+		Redirects:     redirectChain(response),
 	}, nil
 }
 
@@ -338,6 +496,14 @@ func (c *Client) retry() RetryPolicy {
 	return c.retryPolicy.normalized()
 }
 
+func requestHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Host)
+}
+
 func mergeHeaders(target, override http.Header) {
 	for key, values := range override {
 		target.Del(key)
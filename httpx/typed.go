@@ -0,0 +1,285 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details object. Members other than the
+// standard ones are kept in Extensions.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]json.RawMessage
+}
+
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	*p = Problem{}
+	for name, value := range members {
+		var target any
+		switch name {
+		case "type":
+			target = &p.Type
+		case "title":
+			target = &p.Title
+		case "status":
+			target = &p.Status
+		case "detail":
+			target = &p.Detail
+		case "instance":
+			target = &p.Instance
+		default:
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]json.RawMessage)
+			}
+			p.Extensions[name] = value
+			continue
+		}
+		// RFC 9457 requires members of the wrong type to be ignored.
+		_ = json.Unmarshal(value, target)
+	}
+	return nil
+}
+
+// MarshalJSON writes the standard members that are set, in the order type,
+// title, status, detail, instance, followed by Extensions sorted by name.
+// Extensions cannot replace standard members.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	write := func(name string, value any) error {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("problem member %q: %w", name, err)
+		}
+		if buffer.Len() > 1 {
+			buffer.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(data)
+		return nil
+	}
+	standard := []struct {
+		name  string
+		value any
+		set   bool
+	}{
+		{"type", p.Type, p.Type != ""},
+		{"title", p.Title, p.Title != ""},
+		{"status", p.Status, p.Status != 0},
+		{"detail", p.Detail, p.Detail != ""},
+		{"instance", p.Instance, p.Instance != ""},
+	}
+	for _, member := range standard {
+		if member.set {
+			if err := write(member.name, member.value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, name := range sortedKeys(p.Extensions) {
+		switch name {
+		case "type", "title", "status", "detail", "instance":
+			continue
+		}
+		if err := write(name, p.Extensions[name]); err != nil {
+			return nil, err
+		}
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// ProblemError is a non-2xx response with an application/problem+json body.
+type ProblemError struct {
+	*StatusError
+	Problem Problem
+}
+
+func (e *ProblemError) Error() string {
+	message := e.Problem.Title
+	if e.Problem.Detail != "" {
+		if message != "" {
+			message += ": "
+		}
+		message += e.Problem.Detail
+	}
+	if message == "" {
+		return e.StatusError.Error()
+	}
+	return fmt.Sprintf("unexpected HTTP status %d: %s", e.StatusCode, message)
+}
+
+func (e *ProblemError) Unwrap() error {
+	return e.StatusError
+}
+
+// ResponseError is a non-2xx response whose JSON body was decoded into E.
+type ResponseError[E any] struct {
+	*StatusError
+	Body E
+}
+
+func (e *ResponseError[E]) Unwrap() error {
+	return e.StatusError
+}
+
+// ErrorDecoder turns a non-2xx response into an error. The error it returns
+// should unwrap to status so callers can always use errors.As with
+// *StatusError.
+type ErrorDecoder func(status *StatusError) error
+
+// WithErrorDecoder sets the decoder used by Get, Post, and Send for non-2xx
+// responses. The default is DecodeProblem.
+func WithErrorDecoder(decoder ErrorDecoder) Option {
+	return func(client *Client) {
+		client.errorDecoder = decoder
+	}
+}
+
+// DecodeProblem returns a *ProblemError for application/problem+json bodies
+// and status unchanged otherwise.
+func DecodeProblem(status *StatusError) error {
+	mediaType, _, _ := mime.ParseMediaType(status.Header.Get("Content-Type"))
+	if mediaType != ProblemContentType || status.Truncated {
+		return status
+	}
+	var problem Problem
+	if err := json.Unmarshal(status.Body, &problem); err != nil {
+		return status
+	}
+	return &ProblemError{StatusError: status, Problem: problem}
+}
+
+// ErrorBody returns an ErrorDecoder that decodes JSON error bodies into E.
+// Problem details are still decoded as *ProblemError, and bodies that are not
+// valid JSON for E are returned as the plain *StatusError.
+func ErrorBody[E any]() ErrorDecoder {
+	return func(status *StatusError) error {
+		if err := DecodeProblem(status); err != status {
+			return err
+		}
+		var body E
+		if status.Truncated || json.Unmarshal(status.Body, &body) != nil {
+			return status
+		}
+		return &ResponseError[E]{StatusError: status, Body: body}
+	}
+}
+
+// Get sends a GET request and decodes a JSON response into T.
+func Get[T any](ctx context.Context, client *Client, url string) (T, error) {
+	return Send[any, T](ctx, client, http.MethodGet, url, nil, nil)
+}
+
+// Post sends payload as JSON and decodes a JSON response into Resp.
+func Post[Req, Resp any](ctx context.Context, client *Client, url string, payload Req) (Resp, error) {
+	return Send[Req, Resp](ctx, client, http.MethodPost, url, payload, nil)
+}
+
+// Send sends payload as JSON with method and decodes the response into Resp.
+// A nil payload, whether a nil interface, pointer, map, slice, or func, sends
+// no body. Empty and 204 No Content responses leave Resp at its zero value.
+// Non-2xx responses are converted by the client's ErrorDecoder.
+func Send[Req, Resp any](
+	ctx context.Context,
+	client *Client,
+	method string,
+	url string,
+	payload Req,
+	headers http.Header,
+) (Resp, error) {
+	result, _, err := exchangeJSON[Req, Resp](ctx, client, method, url, payload, headers)
+	return result, err
+}
+
+// exchangeJSON implements Send and also returns the buffered response.
+func exchangeJSON[Req, Resp any](
+	ctx context.Context,
+	client *Client,
+	method string,
+	url string,
+	payload Req,
+	headers http.Header,
+) (Resp, *Response, error) {
+	var result Resp
+	if client == nil {
+		client = New()
+	}
+	headers = headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	if headers.Get("Accept") == "" {
+		headers.Set("Accept", "application/json, "+ProblemContentType)
+	}
+	var body io.Reader
+	if value := any(payload); !isNil(value) {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return result, nil, err
+		}
+		body = bytes.NewReader(data)
+		headers.Set("Content-Type", "application/json")
+	}
+
+	response, err := client.Do(ctx, method, url, body, headers)
+	if response != nil && !response.OK() && (err == nil || errors.Is(err, ErrBodyTooLarge)) {
+		status := &StatusError{
+			StatusCode: response.StatusCode,
+			Header:     response.Header,
+			Body:       response.Body,
+			Truncated:  err != nil,
+		}
+		decoder := client.errorDecoder
+		if decoder == nil {
+			decoder = DecodeProblem
+		}
+		if decoded := decoder(status); decoded != nil {
+			return result, response, decoded
+		}
+		return result, response, status
+	}
+	if err != nil {
+		return result, response, err
+	}
+	if response.StatusCode == http.StatusNoContent || len(bytes.TrimSpace(response.Body)) == 0 {
+		return result, response, nil
+	}
+	if err := json.Unmarshal(response.Body, &result); err != nil {
+		return result, response, fmt.Errorf("decode %s response: %w", method, err)
+	}
+	return result, response, nil
+}
+
+// isNil reports whether value is nil or a nil pointer, map, slice, or func,
+// which encoding/json would send as null or reject.
+func isNil(value any) bool {
+	if value == nil {
+		return true
+	}
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func:
+		return reflected.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type widget struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestTypedJSONHelpers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = io.WriteString(w, `{"id":7,"name":"gear"}`)
+		case http.MethodPost:
+			var input widget
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil || r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("input = %+v, error = %v", input, err)
+			}
+			input.ID = 8
+			_ = json.NewEncoder(w).Encode(input)
+		case http.MethodDelete:
+			if body, _ := io.ReadAll(r.Body); len(body) > 0 || r.Header.Get("Content-Type") != "" {
+				t.Errorf("delete body = %q, content type %q", body, r.Header.Get("Content-Type"))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	client := New()
+	got, err := Get[widget](context.Background(), client, server.URL)
+	if err != nil || got != (widget{ID: 7, Name: "gear"}) {
+		t.Fatalf("get = %+v, error = %v", got, err)
+	}
+	created, err := Post[widget, *widget](context.Background(), client, server.URL, widget{Name: "cog"})
+	if err != nil || created == nil || *created != (widget{ID: 8, Name: "cog"}) {
+		t.Fatalf("post = %+v, error = %v", created, err)
+	}
+	deleted, err := Send[any, *widget](context.Background(), client, http.MethodDelete, server.URL, nil, nil)
+	if err != nil || deleted != nil {
+		t.Fatalf("delete = %+v, error = %v", deleted, err)
+	}
+	if _, err := Send[*widget, *widget](context.Background(), client, http.MethodDelete, server.URL, nil, nil); err != nil {
+		t.Fatalf("delete with nil pointer payload: %v", err)
+	}
+	if _, err := Send[[]widget, *widget](context.Background(), client, http.MethodDelete, server.URL, nil, nil); err != nil {
+		t.Fatalf("delete with nil slice payload: %v", err)
+	}
+}
+
+func TestTypedJSONErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/problem" {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `{"type":"https://example.com/out-of-credit","title":"Out of credit",`+
+				`"status":403,"detail":"Balance is 30","balance":30}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = io.WriteString(w, `{"code":"duplicate","message":"already exists"}`)
+	}))
+	defer server.Close()
+
+	_, err := Get[widget](context.Background(), New(), server.URL+"/problem")
+	var problemErr *ProblemError
+	var statusErr *StatusError
+	if !errors.As(err, &problemErr) || !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("error = %#v", err)
+	}
+	if problemErr.Problem.Title != "Out of credit" || problemErr.Problem.Status != 403 ||
+		string(problemErr.Problem.Extensions["balance"]) != "30" ||
+		err.Error() != "unexpected HTTP status 403: Out of credit: Balance is 30" {
+		t.Fatalf("problem = %+v, message = %q", problemErr.Problem, err.Error())
+	}
+
+	type apiError struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	client := New(WithErrorDecoder(ErrorBody[apiError]()))
+	_, err = Post[widget, widget](context.Background(), client, server.URL+"/items", widget{})
+	var responseErr *ResponseError[apiError]
+	if !errors.As(err, &responseErr) || responseErr.Body.Code != "duplicate" || !errors.As(err, &statusErr) ||
+		statusErr.StatusCode != http.StatusConflict {
+		t.Fatalf("error = %#v", err)
+	}
+	_, err = Get[widget](context.Background(), client, server.URL+"/problem")
+	if !errors.As(err, &problemErr) {
+		t.Fatalf("problem with custom decoder = %#v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package httpx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultMaxUploadFileBytes  int64 = 32 << 20
+	defaultMaxUploadFieldBytes int64 = 64 << 10
+	defaultMaxUploadBytes      int64 = 64 << 20
+	defaultMaxUploadFiles            = 10
+	defaultMaxUploadFields           = 100
+	sniffBytes                       = 512
+)
+
+// UploadOptions configures ParseUpload. Zero limits use 32 MiB per file,
+// 64 KiB per field, 64 MiB for the whole body, 10 files, and 100 fields.
+// AllowedTypes lists the media types accepted for files, with patterns such
+// as "image/*"; an empty list accepts any type. The declared part type is
+// checked and, when SniffContent is set, so is the type detected from the
+// file's first 512 bytes. Files are written to Dir, which defaults to the
+// system temporary directory.
+type UploadOptions struct {
+	MaxFileBytes  int64
+	MaxFieldBytes int64
+	MaxTotalBytes int64
+	MaxFiles      int
+	MaxFields     int
+	AllowedTypes  []string
+	SniffContent  bool
+	Dir           string
+}
+
+// UploadForm is a parsed multipart/form-data request. Call RemoveAll once the
+// files are no longer needed.
+type UploadForm struct {
+	Fields url.Values
+	Files  []UploadedFile
+}
+
+// UploadedFile is a file part stored in a temporary file at Path. FileName is
+// the sanitised client filename and ContentType the declared media type;
+// SniffedType is the type detected from the content.
+type UploadedFile struct {
+	Field       string
+	FileName    string
+	ContentType string
+	SniffedType string
+	Size        int64
+	Path        string
+	Header      textproto.MIMEHeader
+}
+
+func (f UploadedFile) Open() (*os.File, error) {
+	return os.Open(f.Path)
+}
+
+// RemoveAll deletes the uploaded files.
+func (u *UploadForm) RemoveAll() error {
+	var errs []error
+	for _, file := range u.Files {
+		if err := os.Remove(file.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ParseUpload reads a multipart/form-data request, streaming file parts to
+// temporary files and keeping other fields in memory. Failures caused by the
+// request are *ValidationError values naming the offending field; the files
+// stored before a failure are removed.
+func ParseUpload(w http.ResponseWriter, r *http.Request, options UploadOptions) (*UploadForm, error) {
+	options = options.normalized()
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil, &ValidationError{
+			Status: http.StatusUnsupportedMediaType,
+			Detail: fmt.Sprintf("content type %q is not multipart/form-data", r.Header.Get("Content-Type")),
+			Err:    err,
+		}
+	}
+	body := http.MaxBytesReader(w, r.Body, options.MaxTotalBytes)
+	defer body.Close()
+	reader := multipart.NewReader(body, params["boundary"])
+
+	upload := &UploadForm{Fields: make(url.Values)}
+	fields := 0
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return upload, nil
+		}
+		if err == nil {
+			if part.FileName() != "" {
+				err = options.saveFile(upload, part)
+			} else {
+				fields++
+				err = options.readField(upload, part, fields)
+			}
+			_ = part.Close()
+		}
+		if err != nil {
+			_ = upload.RemoveAll()
+			return nil, uploadError(err, options)
+		}
+	}
+}
+
+func (o UploadOptions) normalized() UploadOptions {
+	if o.MaxFileBytes <= 0 {
+		o.MaxFileBytes = defaultMaxUploadFileBytes
+	}
+	if o.MaxFieldBytes <= 0 {
+		o.MaxFieldBytes = defaultMaxUploadFieldBytes
+	}
+	if o.MaxTotalBytes <= 0 {
+		o.MaxTotalBytes = defaultMaxUploadBytes
+	}
+	if o.MaxFiles <= 0 {
+		o.MaxFiles = defaultMaxUploadFiles
+	}
+	if o.MaxFields <= 0 {
+		o.MaxFields = defaultMaxUploadFields
+	}
+	if o.Dir == "" {
+		o.Dir = os.TempDir()
+	}
+	return o
+}
+
+func (o UploadOptions) readField(upload *UploadForm, part *multipart.Part, count int) error {
+	name := part.FormName()
+	if count > o.MaxFields {
+		return &ValidationError{
+			Status: http.StatusRequestEntityTooLarge,
+			Fields: []FieldError{{Field: name, Detail: fmt.Sprintf("more than %d fields", o.MaxFields)}},
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(part, o.MaxFieldBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > o.MaxFieldBytes {
+		return &ValidationError{
+			Status: http.StatusRequestEntityTooLarge,
+			Fields: []FieldError{{Field: name, Detail: fmt.Sprintf("exceeds %d bytes", o.MaxFieldBytes)}},
+		}
+	}
+	upload.Fields.Add(name, string(data))
+	return nil
+}
+
+func (o UploadOptions) saveFile(upload *UploadForm, part *multipart.Part) error {
+	name := part.FormName()
+	invalid := func(status int, detail string) error {
+		return &ValidationError{Status: status, Fields: []FieldError{{Field: name, Detail: detail}}}
+	}
+	if len(upload.Files) >= o.MaxFiles {
+		return invalid(http.StatusRequestEntityTooLarge, fmt.Sprintf("more than %d files", o.MaxFiles))
+	}
+	declared := part.Header.Get("Content-Type")
+	if declared == "" {
+		declared = "application/octet-stream"
+	}
+	if !o.allowsType(declared) {
+		return invalid(http.StatusUnsupportedMediaType, fmt.Sprintf("content type %q is not allowed", declared))
+	}
+
+	head := make([]byte, sniffBytes)
+	count, err := io.ReadFull(part, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	head = head[:count]
+	sniffed := http.DetectContentType(head)
+	if o.SniffContent && !o.allowsType(sniffed) {
+		return invalid(http.StatusUnsupportedMediaType, fmt.Sprintf("content detected as %q is not allowed", sniffed))
+	}
+
+	fileName := SanitizeFileName(part.FileName())
+	file, err := os.CreateTemp(o.Dir, "upload-*"+filepath.Ext(fileName))
+	if err != nil {
+		return err
+	}
+	limited := io.LimitReader(io.MultiReader(bytes.NewReader(head), part), o.MaxFileBytes+1)
+	size, err := io.Copy(file, limited)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > o.MaxFileBytes {
+		err = invalid(http.StatusRequestEntityTooLarge, fmt.Sprintf("exceeds %d bytes", o.MaxFileBytes))
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	upload.Files = append(upload.Files, UploadedFile{
+		Field:       name,
+		FileName:    fileName,
+		ContentType: declared,
+		SniffedType: sniffed,
+		Size:        size,
+		Path:        file.Name(),
+		Header:      part.Header,
+	})
+	return nil
+}
+
+func (o UploadOptions) allowsType(contentType string) bool {
+	if len(o.AllowedTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range o.AllowedTypes {
+		if contentTypeMatches(mediaType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadError converts errors from reading the request into
+// *ValidationError values.
+func uploadError(err error, options UploadOptions) error {
+	var invalid *ValidationError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &invalid):
+		return err
+	case errors.As(err, &tooLarge):
+		return &ValidationError{
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("request body exceeds %d bytes", options.MaxTotalBytes),
+			Err:    err,
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF), strings.HasPrefix(err.Error(), "multipart: "):
+		return &ValidationError{Status: http.StatusBadRequest, Detail: "malformed multipart body", Err: err}
+	}
+	return err
+}
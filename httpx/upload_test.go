@@ -0,0 +1,135 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func uploadServer(t *testing.T, options UploadOptions, handle func(*UploadForm, error)) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		form, err := ParseUpload(w, r, options)
+		handle(form, err)
+		var invalid *ValidationError
+		if errors.As(err, &invalid) {
+			_ = WriteProblem(w, invalid.Problem())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func TestParseUploadStoresFiles(t *testing.T) {
+	dir := t.TempDir()
+	var form *UploadForm
+	server := uploadServer(t, UploadOptions{Dir: dir, AllowedTypes: []string{"text/*", "application/octet-stream"}, SniffContent: true},
+		func(parsed *UploadForm, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			form = parsed
+		})
+	defer server.Close()
+
+	multipart := NewMultipart()
+	if err := multipart.AddField("title", "notes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := multipart.AddBytes("doc", `..\..\notes.txt`, []byte("hello upload")); err != nil {
+		t.Fatal(err)
+	}
+	response, err := New().PostMultipart(context.Background(), server.URL, multipart, nil)
+	if err != nil || response.StatusCode != http.StatusNoContent {
+		t.Fatalf("response = %+v, err = %v", response, err)
+	}
+	if form.Fields.Get("title") != "notes" || len(form.Files) != 1 {
+		t.Fatalf("form = %+v", form)
+	}
+	file := form.Files[0]
+	if file.Field != "doc" || file.FileName != "notes.txt" || !strings.HasPrefix(file.SniffedType, "text/plain") ||
+		file.Size != 12 || !strings.HasPrefix(file.Path, dir) {
+		t.Fatalf("file = %+v", file)
+	}
+	if content, _ := os.ReadFile(file.Path); string(content) != "hello upload" {
+		t.Fatalf("content = %q", content)
+	}
+	if err := form.RemoveAll(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("files left after RemoveAll: %v", entries)
+	}
+}
+
+func TestParseUploadRejectsInvalidParts(t *testing.T) {
+	tests := []struct {
+		name    string
+		options UploadOptions
+		build   func(*Multipart) error
+		status  int
+	}{
+		{
+			name:    "file too large",
+			options: UploadOptions{MaxFileBytes: 4},
+			build:   func(form *Multipart) error { return form.AddBytes("doc", "a.txt", []byte("too long")) },
+			status:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:    "field too large",
+			options: UploadOptions{MaxFieldBytes: 4},
+			build:   func(form *Multipart) error { return form.AddField("note", "too long") },
+			status:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:    "declared type",
+			options: UploadOptions{AllowedTypes: []string{"image/png"}},
+			build:   func(form *Multipart) error { return form.AddBytes("doc", "a.txt", []byte("text")) },
+			status:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:    "sniffed type",
+			options: UploadOptions{AllowedTypes: []string{"application/octet-stream"}, SniffContent: true},
+			build:   func(form *Multipart) error { return form.AddBytes("doc", "a.bin", []byte("<html>")) },
+			status:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:    "total",
+			options: UploadOptions{MaxTotalBytes: 64},
+			build:   func(form *Multipart) error { return form.AddBytes("doc", "a.txt", make([]byte, 100)) },
+			status:  http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			test.options.Dir = dir
+			server := uploadServer(t, test.options, func(*UploadForm, error) {})
+			defer server.Close()
+			form := NewMultipart()
+			if err := test.build(form); err != nil {
+				t.Fatal(err)
+			}
+			response, err := New().PostMultipart(context.Background(), server.URL, form, nil)
+			if err != nil || response.StatusCode != test.status ||
+				response.Header.Get("Content-Type") != ProblemContentType {
+				t.Fatalf("response = %+v, err = %v", response, err)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Fatalf("files left after failure: %v", entries)
+			}
+		})
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+	request.Header.Set("Content-Type", "application/json")
+	var invalid *ValidationError
+	if _, err := ParseUpload(httptest.NewRecorder(), request, UploadOptions{}); !errors.As(err, &invalid) ||
+		invalid.Status != http.StatusUnsupportedMediaType {
+		t.Fatalf("err = %v", err)
+	}
+}